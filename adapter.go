@@ -8,11 +8,82 @@ import (
 )
 
 // DNSRecord contains the minimal set of data needed to represent an A DNS record.
+// With Type it may also be a record of another type. Records of the same Key
+// are the values of a single record set, e.g. the TXT records of a name.
 type DNSRecord struct {
 	Alias bool
+	// Type is the DNS type of a record that is not an Alias.
+	Type RecordType
 
-	Name   config.Domain
+	Name config.Domain
+	// Target is the value of the record, e.g. the IP of an A record
+	// or the text of a TXT record without quotes.
 	Target config.Domain
+	// TTL is the TTL of a record that is not an Alias in seconds.
+	// Zero means the default TTL of the adapter, or that the adapter can't tell.
+	// The TTLs of two records are only compared if both have one.
+	TTL int64
+
+	// TargetZoneID is the hosted zone ID of an alias Target.
+	// Empty means the zone that hosts Target.
+	TargetZoneID string
+	// IgnoreTargetHealth disables the health evaluation of an alias Target.
+	IgnoreTargetHealth bool
+
+	// Routing is the routing policy of the record.
+	Routing config.Routing
+}
+
+// RecordType is the DNS type of a record.
+type RecordType string
+
+// Available Record Types. The zero value is A, so records without a Type are A records.
+const (
+	TypeA     RecordType = ""
+	TypeAAAA  RecordType = "AAAA"
+	TypeCNAME RecordType = "CNAME"
+	TypeTXT   RecordType = "TXT"
+	TypeMX    RecordType = "MX"
+	TypeCAA   RecordType = "CAA"
+	TypeSRV   RecordType = "SRV"
+	TypePTR   RecordType = "PTR"
+)
+
+// String returns the name of the type as used in zone files.
+func (t RecordType) String() string {
+	if t == TypeA {
+		return "A"
+	}
+	return string(t)
+}
+
+// RecordTypeOfIP returns AAAA for an IPv6 address and A otherwise.
+func RecordTypeOfIP(ip string) RecordType {
+	if strings.Contains(ip, ":") {
+		return TypeAAAA
+	}
+	return TypeA
+}
+
+// RecordKey identifies a record among the records of a zone.
+type RecordKey struct {
+	Name          config.Domain
+	Type          RecordType
+	SetIdentifier string
+}
+
+// Key returns the RecordKey of the record.
+// Aliases share the key of the A records of their name.
+func (r DNSRecord) Key() RecordKey {
+	t := r.Type
+	if r.Alias {
+		t = TypeA
+	}
+	return RecordKey{
+		Name:          r.Name,
+		Type:          t,
+		SetIdentifier: r.Routing.SetIdentifier,
+	}
 }
 
 // NewAliasRecord constructs an alias DNSRecord from input strings.
@@ -25,9 +96,20 @@ func NewAliasRecord(name, target string) DNSRecord {
 }
 
 // NewRecord constructs a non-alias DNSRecord from input strings.
+// The record is an AAAA record if target is an IPv6 address, an A record otherwise.
 func NewRecord(name, target string) DNSRecord {
 	return DNSRecord{
 		Alias:  false,
+		Type:   RecordTypeOfIP(target),
+		Name:   config.Domain(name),
+		Target: config.Domain(target),
+	}
+}
+
+// NewCNAMERecord constructs a CNAME DNSRecord from input strings.
+func NewCNAMERecord(name, target string) DNSRecord {
+	return DNSRecord{
+		Type:   TypeCNAME,
 		Name:   config.Domain(name),
 		Target: config.Domain(target),
 	}
@@ -65,6 +147,32 @@ type Action struct {
 	Record DNSRecord
 }
 
+// AliasStrategy is how an adapter represents the edges of the alias trees.
+type AliasStrategy int
+
+// Available Alias Strategies
+const (
+	// AliasRecords are provider-specific aliases, e.g. Route53 alias records.
+	AliasRecords AliasStrategy = iota
+	// CNAMERecords are standard CNAME records. Where a CNAME is not allowed,
+	// at a zone apex or next to other records of the name,
+	// the name gets the addresses of the root of its tree instead.
+	CNAMERecords
+)
+
+// AliasStrategist is implemented by adapters that don't use AliasRecords.
+type AliasStrategist interface {
+	AliasStrategy() AliasStrategy
+}
+
+// AliasStrategyOf returns the AliasStrategy of the adapter, AliasRecords unless it is an AliasStrategist.
+func AliasStrategyOf(adapter interface{}) AliasStrategy {
+	if s, ok := adapter.(AliasStrategist); ok {
+		return s.AliasStrategy()
+	}
+	return AliasRecords
+}
+
 // Lister implements List.
 type Lister interface {
 	// List lists all currently existing DNS records that the adapter has access to.
@@ -0,0 +1,537 @@
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+	"gopkg.in/yaml.v3"
+)
+
+// CoreDNSFormat is the CoreDNS plugin whose configuration the CoreDNS adapter renders.
+type CoreDNSFormat string
+
+// Available CoreDNS Formats
+const (
+	// CoreDNSHosts renders a single file for the `hosts` plugin.
+	CoreDNSHosts CoreDNSFormat = "hosts"
+	// CoreDNSFile renders one zone file per zone for the `file` plugin.
+	CoreDNSFile CoreDNSFormat = "file"
+)
+
+const (
+	aliasComment   = "dnser:alias"
+	cnameComment   = "dnser:cname"
+	zoneFilePrefix = "db."
+)
+
+// CoreDNS is an Adapter that keeps DNS records in CoreDNS configuration files.
+//
+// With CoreDNSHosts the path is a hosts file. Alias records are resolved
+// to the IPs of the record they point to and annotated with a comment,
+// so that List can read them back as aliases. Hosts files only have addresses,
+// so Process fails for records of the other types.
+//
+// With CoreDNSFile the path is a directory that contains a "db.<zone>" file
+// per zone. The zone files have no aliases, so the adapter uses dnser.CNAMERecords.
+// Alias records given anyway are rendered as CNAME records.
+// Zone files keep records of every dnser.RecordType.
+type CoreDNS struct {
+	path      string
+	format    CoreDNSFormat
+	configMap *CoreDNSConfigMap
+}
+
+// CoreDNSConfigMap describes a Kubernetes ConfigMap manifest
+// that is written next to the CoreDNS configuration files.
+type CoreDNSConfigMap struct {
+	Path      string
+	Name      string
+	Namespace string
+}
+
+// NewCoreDNS constructs a CoreDNS instance that manages the configuration at path.
+func NewCoreDNS(path string, format CoreDNSFormat) CoreDNS {
+	return CoreDNS{
+		path:   path,
+		format: format,
+	}
+}
+
+// AliasStrategy implements dnser.AliasStrategist.
+func (a CoreDNS) AliasStrategy() dnser.AliasStrategy {
+	if a.format == CoreDNSFile {
+		return dnser.CNAMERecords
+	}
+	return dnser.AliasRecords
+}
+
+// WithConfigMap returns a copy of the adapter that also writes
+// the rendered configuration as a ConfigMap manifest.
+func (a CoreDNS) WithConfigMap(cm CoreDNSConfigMap) CoreDNS {
+	a.configMap = &cm
+	return a
+}
+
+// List parses the DNS records from the CoreDNS configuration files.
+// Missing files are treated as empty.
+func (a CoreDNS) List(ctx context.Context) ([]dnser.DNSRecord, error) {
+	files, err := a.readFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]dnser.DNSRecord, 0)
+	for _, name := range sortedKeys(files) {
+		var fileRecords []dnser.DNSRecord
+		switch a.format {
+		case CoreDNSHosts:
+			fileRecords, err = parseHosts(files[name])
+		case CoreDNSFile:
+			fileRecords, err = parseZoneFile(files[name])
+		default:
+			err = fmt.Errorf("unknown CoreDNS format %q", a.format)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		records = append(records, fileRecords...)
+	}
+	return records, nil
+}
+
+// Process applies the actions to the records from the configuration files
+// and writes the result back.
+func (a CoreDNS) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	records, err := a.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, actions := range actionGroups {
+		records = applyActions(records, actions)
+	}
+
+	files, err := a.render(records)
+	if err != nil {
+		return err
+	}
+	return a.writeFiles(files)
+}
+
+// applyActions upserts record sets by key and deletes records that match exactly.
+func applyActions(records []dnser.DNSRecord, actions []dnser.Action) []dnser.DNSRecord {
+	upserts := make([]dnser.DNSRecord, 0, len(actions))
+	for _, action := range actions {
+		if action.Type == dnser.Upsert {
+			upserts = append(upserts, action.Record)
+		}
+	}
+
+	result := make([]dnser.DNSRecord, 0, len(records))
+	for _, r := range records {
+		if isReplaced(r, upserts) || isDeleted(r, actions) {
+			continue
+		}
+		result = append(result, r)
+	}
+	return append(result, upserts...)
+}
+
+// isReplaced returns whether one of the upserted records replaces the record:
+// a record set replaces the set of its key, and a CNAME is the only record of its name.
+func isReplaced(record dnser.DNSRecord, upserts []dnser.DNSRecord) bool {
+	for _, u := range upserts {
+		if u.Key() == record.Key() {
+			return true
+		}
+		if u.Name == record.Name && (isCNAME(u) || isCNAME(record)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCNAME(r dnser.DNSRecord) bool {
+	return !r.Alias && r.Type == dnser.TypeCNAME
+}
+
+func isDeleted(record dnser.DNSRecord, actions []dnser.Action) bool {
+	for _, action := range actions {
+		if action.Type == dnser.Delete && action.Record == record {
+			return true
+		}
+	}
+	return false
+}
+
+func (a CoreDNS) render(records []dnser.DNSRecord) (map[string]string, error) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+
+	switch a.format {
+	case CoreDNSHosts:
+		hosts, err := renderHosts(records)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{filepath.Base(a.path): hosts}, nil
+	case CoreDNSFile:
+		return renderZoneFiles(records), nil
+	default:
+		return nil, fmt.Errorf("unknown CoreDNS format %q", a.format)
+	}
+}
+
+func renderHosts(records []dnser.DNSRecord) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Managed by dnser. Do not edit.\n")
+	for _, r := range records {
+		if !r.Alias {
+			switch r.Type {
+			case dnser.TypeA, dnser.TypeAAAA:
+				fmt.Fprintf(&b, "%s %s\n", r.Target, hostName(r.Name))
+				continue
+			case dnser.TypeCNAME:
+			default:
+				// the hosts plugin synthesizes the PTR records of its names itself
+				return "", fmt.Errorf("%s: the hosts format has no %s records", r.Name, r.Type)
+			}
+		}
+		ips, err := resolveAlias(r.Target, records, map[config.Domain]bool{r.Name: true})
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", r.Name, err)
+		}
+		comment := aliasComment
+		if !r.Alias {
+			comment = cnameComment
+		}
+		for _, ip := range ips {
+			fmt.Fprintf(&b, "%s %s # %s %s\n", ip, hostName(r.Name), comment, r.Target)
+		}
+	}
+	return b.String(), nil
+}
+
+// resolveAlias follows the alias chain starting at name and returns the IPs it ends at.
+func resolveAlias(name config.Domain, records []dnser.DNSRecord, seen map[config.Domain]bool) ([]config.Domain, error) {
+	if seen[name] {
+		return nil, fmt.Errorf("alias loop at %s", name)
+	}
+	seen[name] = true
+
+	ips := make([]config.Domain, 0)
+	for _, r := range records {
+		if r.Name != name {
+			continue
+		}
+		if !isAliasOrCNAME(r) {
+			ips = append(ips, r.Target)
+			continue
+		}
+		targetIPs, err := resolveAlias(r.Target, records, seen)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, targetIPs...)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s does not resolve to an IP", name)
+	}
+	return ips, nil
+}
+
+func renderZoneFiles(records []dnser.DNSRecord) map[string]string {
+	zones := make(map[config.Domain][]dnser.DNSRecord)
+	for _, r := range records {
+		zones[r.NameZone()] = append(zones[r.NameZone()], r)
+	}
+
+	serial := uint32(time.Now().Unix())
+	files := make(map[string]string, len(zones))
+	for zone, zoneRecords := range zones {
+		var b strings.Builder
+		b.WriteString("; Managed by dnser. Do not edit.\n")
+		fmt.Fprintf(&b, "$ORIGIN %s\n", zone)
+		fmt.Fprintf(&b, "@ %d IN SOA ns.dns.%s hostmaster.%s %d 7200 1800 86400 %d\n",
+			defaultTTL, zone, zone, serial, defaultTTL)
+		for _, r := range zoneRecords {
+			rrType, value := r.Type, string(r.Target)
+			switch {
+			case r.Alias:
+				rrType = dnser.TypeCNAME
+			case r.Type == dnser.TypeTXT:
+				value = quoteTXT(value)
+			}
+			fmt.Fprintf(&b, "%s %d IN %s %s\n", r.Name, recordTTL(r), rrType, value)
+		}
+		files[zoneFilePrefix+hostName(zone)] = b.String()
+	}
+	return files
+}
+
+func parseHosts(data string) ([]dnser.DNSRecord, error) {
+	records := make([]dnser.DNSRecord, 0)
+	seenAliases := make(map[config.Domain]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line, comment := splitComment(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("malformed hosts line %q", scanner.Text())
+		}
+
+		marker, target, isAlias := aliasTarget(comment)
+		for _, name := range fields[1:] {
+			if !isAlias {
+				records = append(records, dnser.NewRecord(fqdn(name), fields[0]))
+				continue
+			}
+			// resolved aliases are written once per IP
+			if seenAliases[config.Domain(fqdn(name))] {
+				continue
+			}
+			seenAliases[config.Domain(fqdn(name))] = true
+			if marker == cnameComment {
+				records = append(records, dnser.NewCNAMERecord(fqdn(name), target))
+			} else {
+				records = append(records, dnser.NewAliasRecord(fqdn(name), target))
+			}
+		}
+	}
+	return records, scanner.Err()
+}
+
+func parseZoneFile(data string) ([]dnser.DNSRecord, error) {
+	records := make([]dnser.DNSRecord, 0)
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := stripZoneComment(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "$") {
+			continue
+		}
+		if len(fields) < 5 {
+			continue // records without a TTL are not written by dnser
+		}
+		value := zoneFileValue(line)
+
+		var record dnser.DNSRecord
+		switch rrType := dnser.RecordType(fields[3]); rrType {
+		case "A", dnser.TypeAAAA:
+			record = dnser.NewRecord(fields[0], value)
+		case dnser.TypeCNAME:
+			record = dnser.NewCNAMERecord(fields[0], value)
+		case dnser.TypeTXT:
+			record = dnser.DNSRecord{Type: rrType, Name: config.Domain(fields[0]), Target: config.Domain(unquoteTXT(value))}
+		case dnser.TypeMX, dnser.TypeCAA, dnser.TypeSRV, dnser.TypePTR:
+			record = dnser.DNSRecord{Type: rrType, Name: config.Domain(fields[0]), Target: config.Domain(value)}
+		default:
+			continue // SOA and other records dnser does not manage
+		}
+		ttl, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed zone file line %q: %w", scanner.Text(), err)
+		}
+		record.TTL = ttl
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// zoneFileValue returns the value of a record line, i.e. everything after the name, TTL, class and type.
+// The value may contain spaces, e.g. in the quoted text of a TXT record.
+func zoneFileValue(line string) string {
+	for i := 0; i < 4; i++ {
+		line = strings.TrimLeft(line, " \t")
+		line = line[strings.IndexAny(line, " \t"):]
+	}
+	return strings.TrimSpace(line)
+}
+
+// stripZoneComment removes the comment of a zone file line. A ; inside quotes is not a comment.
+func stripZoneComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func splitComment(line, marker string) (string, string) {
+	i := strings.Index(line, marker)
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i+len(marker):])
+}
+
+// aliasTarget parses the comment of a resolved alias or CNAME
+// and returns its marker and target.
+func aliasTarget(comment string) (string, string, bool) {
+	fields := strings.Fields(comment)
+	if len(fields) != 2 || (fields[0] != aliasComment && fields[0] != cnameComment) {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+func isAliasOrCNAME(r dnser.DNSRecord) bool {
+	return r.Alias || r.Type == dnser.TypeCNAME
+}
+
+func hostName(domain config.Domain) string {
+	return strings.TrimSuffix(string(domain), ".")
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+func (a CoreDNS) readFiles() (map[string]string, error) {
+	paths := []string{a.path}
+	if a.format == CoreDNSFile {
+		var err error
+		paths, err = filepath.Glob(filepath.Join(a.path, zoneFilePrefix+"*"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(path)] = string(data)
+	}
+	return files, nil
+}
+
+func (a CoreDNS) writeFiles(files map[string]string) error {
+	dir := filepath.Dir(a.path)
+	if a.format == CoreDNSFile {
+		dir = a.path
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := removeStaleZoneFiles(dir, files); err != nil {
+			return err
+		}
+	}
+
+	for name, data := range files {
+		if err := writeFileAtomic(filepath.Join(dir, name), []byte(data)); err != nil {
+			return err
+		}
+	}
+
+	if a.configMap == nil {
+		return nil
+	}
+	manifest, err := a.configMap.render(files)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(a.configMap.Path, manifest)
+}
+
+func removeStaleZoneFiles(dir string, files map[string]string) error {
+	existing, err := filepath.Glob(filepath.Join(dir, zoneFilePrefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		if _, ok := files[filepath.Base(path)]; ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+type configMapManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   configMapMetadata `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type configMapMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+func (cm CoreDNSConfigMap) render(files map[string]string) ([]byte, error) {
+	return yaml.Marshal(configMapManifest{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata: configMapMetadata{
+			Name:      cm.Name,
+			Namespace: cm.Namespace,
+		},
+		Data: files,
+	})
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
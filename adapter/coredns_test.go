@@ -0,0 +1,179 @@
+package adapter
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flood4life/dnser"
+)
+
+var coreDNSActions = [][]dnser.Action{{{
+	Type:   dnser.Upsert,
+	Record: dnser.NewRecord("example.org.", "127.0.0.1"),
+}}, {{
+	Type:   dnser.Upsert,
+	Record: dnser.NewAliasRecord("foo.example.org.", "example.org."),
+}}, {{
+	Type:   dnser.Upsert,
+	Record: dnser.NewAliasRecord("bar.example.org.", "foo.example.org."),
+}}}
+
+func TestCoreDNS_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format CoreDNSFormat
+		path   string
+		want   []dnser.DNSRecord
+	}{{
+		name:   "hosts",
+		format: CoreDNSHosts,
+		path:   "hosts",
+		want: []dnser.DNSRecord{
+			dnser.NewAliasRecord("bar.example.org.", "foo.example.org."),
+			dnser.NewRecord("example.org.", "127.0.0.1"),
+			dnser.NewAliasRecord("foo.example.org.", "example.org."),
+		},
+	}, {
+		name:   "file",
+		format: CoreDNSFile,
+		path:   "zones",
+		// zone files have no aliases, but a TTL for every record
+		want: withDefaultTTL([]dnser.DNSRecord{
+			dnser.NewCNAMERecord("bar.example.org.", "foo.example.org."),
+			dnser.NewRecord("example.org.", "127.0.0.1"),
+			dnser.NewCNAMERecord("foo.example.org.", "example.org."),
+		}),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := NewCoreDNS(filepath.Join(dir, tt.path), tt.format)
+			if err := a.Process(context.Background(), coreDNSActions); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			got, err := a.List(context.Background())
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoreDNS_ProcessDelete(t *testing.T) {
+	dir := t.TempDir()
+	a := NewCoreDNS(filepath.Join(dir, "hosts"), CoreDNSHosts)
+	if err := a.Process(context.Background(), coreDNSActions); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	err := a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Delete,
+		Record: dnser.NewAliasRecord("bar.example.org.", "foo.example.org."),
+	}}})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []dnser.DNSRecord{
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		dnser.NewAliasRecord("foo.example.org.", "example.org."),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() got = %v, want %v", got, want)
+	}
+}
+
+func TestCoreDNS_ConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	a := NewCoreDNS(filepath.Join(dir, "hosts"), CoreDNSHosts).WithConfigMap(CoreDNSConfigMap{
+		Path:      filepath.Join(dir, "configmap.yaml"),
+		Name:      "coredns-dnser",
+		Namespace: "kube-system",
+	})
+	if err := a.Process(context.Background(), coreDNSActions); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	manifest, err := ioutil.ReadFile(filepath.Join(dir, "configmap.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"kind: ConfigMap", "name: coredns-dnser", "127.0.0.1 foo.example.org # dnser:alias example.org."} {
+		if !strings.Contains(string(manifest), want) {
+			t.Errorf("manifest does not contain %q:\n%s", want, manifest)
+		}
+	}
+}
+
+func TestCoreDNS_FileRecordTypes(t *testing.T) {
+	records := []dnser.DNSRecord{
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		dnser.NewRecord("example.org.", "::1"),
+		{Type: dnser.TypeTXT, Name: "example.org.", Target: `v=DKIM1; k=rsa; n="quoted \ text"`, TTL: 60},
+		{Type: dnser.TypeMX, Name: "example.org.", Target: "10 mail.example.org.", TTL: 60},
+		{Type: dnser.TypeCAA, Name: "example.org.", Target: `0 issue "letsencrypt.org"`, TTL: 60},
+		{Type: dnser.TypeSRV, Name: "_http._tcp.example.org.", Target: "10 5 8080 www.example.org.", TTL: 60},
+		{Type: dnser.TypePTR, Name: "1.0.0.127.in-addr.arpa.", Target: "example.org.", TTL: 60},
+	}
+	for _, record := range records {
+		t.Run(string(record.Type), func(t *testing.T) {
+			a := NewCoreDNS(filepath.Join(t.TempDir(), "zones"), CoreDNSFile)
+			err := a.Process(context.Background(), [][]dnser.Action{{{Type: dnser.Upsert, Record: record}}})
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			got, err := a.List(context.Background())
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			want := withDefaultTTL([]dnser.DNSRecord{record})
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("List() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCoreDNS_FileUpsertKeepsOtherTypes(t *testing.T) {
+	a := NewCoreDNS(filepath.Join(t.TempDir(), "zones"), CoreDNSFile)
+	if err := a.Process(context.Background(), coreDNSActions); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	txt := dnser.DNSRecord{Type: dnser.TypeTXT, Name: "example.org.", Target: "v=spf1 -all", TTL: 300}
+	if err := a.Process(context.Background(), [][]dnser.Action{{{Type: dnser.Upsert, Record: txt}}}); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := withDefaultTTL([]dnser.DNSRecord{
+		dnser.NewCNAMERecord("bar.example.org.", "foo.example.org."),
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		txt,
+		dnser.NewCNAMERecord("foo.example.org.", "example.org."),
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() got = %v, want %v", got, want)
+	}
+}
+
+func TestCoreDNS_HostsRecordTypes(t *testing.T) {
+	a := NewCoreDNS(filepath.Join(t.TempDir(), "hosts"), CoreDNSHosts)
+	txt := dnser.DNSRecord{Type: dnser.TypeTXT, Name: "example.org.", Target: "v=spf1 -all"}
+	if err := a.Process(context.Background(), [][]dnser.Action{{{Type: dnser.Upsert, Record: txt}}}); err == nil {
+		t.Error("Process() error = nil, want an error for a TXT record")
+	}
+}
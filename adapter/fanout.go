@@ -0,0 +1,232 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flood4life/dnser"
+	"golang.org/x/sync/errgroup"
+)
+
+// ListMode controls how FanOut lists records.
+type ListMode int
+
+// Available List Modes
+const (
+	// ListPrimary lists records from the primary backend only.
+	ListPrimary ListMode = iota
+	// ListConsensus lists records from every backend
+	// and returns a DivergenceError if they disagree.
+	ListConsensus
+)
+
+// FailureMode controls how FanOut reacts when a backend fails to process the actions.
+type FailureMode int
+
+// Available Failure Modes
+const (
+	// FailFast cancels the other backends as soon as any backend fails.
+	FailFast FailureMode = iota
+	// FailPrimary cancels the secondary backends only if the primary backend fails.
+	// Failures of secondary backends are collected and returned at the end.
+	FailPrimary
+	// BestEffort lets every backend finish
+	// and returns all collected failures at the end.
+	BestEffort
+)
+
+// Backend is a named Adapter that FanOut delegates to.
+type Backend struct {
+	Name    string
+	Adapter dnser.Adapter
+}
+
+// FanOut is an Adapter that mirrors the changes to several backends.
+// The first backend is the primary one.
+type FanOut struct {
+	backends    []Backend
+	listMode    ListMode
+	failureMode FailureMode
+}
+
+// NewFanOut constructs a FanOut instance from the primary and the secondary backends.
+func NewFanOut(listMode ListMode, failureMode FailureMode, primary Backend, secondaries ...Backend) FanOut {
+	return FanOut{
+		backends:    append([]Backend{primary}, secondaries...),
+		listMode:    listMode,
+		failureMode: failureMode,
+	}
+}
+
+// DivergenceError is returned by FanOut.List in ListConsensus mode
+// when a backend has records that differ from the primary.
+type DivergenceError struct {
+	Backend string
+	Missing []dnser.DNSRecord // present in the primary, absent in Backend
+	Extra   []dnser.DNSRecord // present in Backend, absent in the primary
+}
+
+// Error implements error.
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("backend %s diverges from the primary: %d missing, %d extra records",
+		e.Backend, len(e.Missing), len(e.Extra))
+}
+
+// BackendError is the failure of a single backend.
+type BackendError struct {
+	Backend string
+	Err     error
+}
+
+// Error implements error.
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("backend %s: %v", e.Backend, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// FanOutError collects the backend failures of FanOut.Process.
+type FanOutError struct {
+	Errors []*BackendError
+}
+
+// Error implements error.
+func (e *FanOutError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// List returns the records of the primary backend.
+// In ListConsensus mode every backend is listed and compared to the primary.
+func (a FanOut) List(ctx context.Context) ([]dnser.DNSRecord, error) {
+	if a.listMode == ListPrimary {
+		return a.backends[0].Adapter.List(ctx)
+	}
+
+	g, gCtx := errgroup.WithContext(ctx)
+	records := make([][]dnser.DNSRecord, len(a.backends))
+	for i, backend := range a.backends {
+		i, backend := i, backend
+		g.Go(func() error {
+			backendRecords, err := backend.Adapter.List(gCtx)
+			if err != nil {
+				return &BackendError{Backend: backend.Name, Err: err}
+			}
+			records[i] = backendRecords
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i, backend := range a.backends[1:] {
+		missing, extra := diffRecords(records[0], records[i+1])
+		if len(missing) > 0 || len(extra) > 0 {
+			return records[0], &DivergenceError{
+				Backend: backend.Name,
+				Missing: missing,
+				Extra:   extra,
+			}
+		}
+	}
+	return records[0], nil
+}
+
+// diffRecords returns records of want that are absent in have, and vice versa.
+func diffRecords(want, have []dnser.DNSRecord) ([]dnser.DNSRecord, []dnser.DNSRecord) {
+	return subtractRecords(want, have), subtractRecords(have, want)
+}
+
+func subtractRecords(from, records []dnser.DNSRecord) []dnser.DNSRecord {
+	present := make(map[dnser.DNSRecord]bool, len(records))
+	for _, r := range records {
+		present[r] = true
+	}
+
+	result := make([]dnser.DNSRecord, 0)
+	for _, r := range from {
+		if !present[r] {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// Process passes all action groups to every backend concurrently, in a single Process call per backend,
+// so that a backend that applies them atomically, e.g. Route53 WithRollback, still does.
+// Depending on the FailureMode a failed backend cancels the context of the others.
+func (a FanOut) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	secondaryCtx, cancelSecondaries := context.WithCancel(ctx)
+	defer cancelSecondaries()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make([]*BackendError, 0)
+	)
+	for i, backend := range a.backends {
+		backendCtx := ctx
+		if i > 0 {
+			backendCtx = secondaryCtx
+		}
+		i, backend := i, backend
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := backend.Adapter.Process(backendCtx, actionGroups)
+			if err == nil {
+				return
+			}
+			switch {
+			case a.failureMode == FailFast:
+				cancel()
+			case a.failureMode == FailPrimary && i == 0:
+				cancelSecondaries()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, &BackendError{Backend: backend.Name, Err: err})
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return a.backendIndex(errs[i].Backend) < a.backendIndex(errs[j].Backend)
+	})
+	return &FanOutError{Errors: errs}
+}
+
+// AliasStrategy implements dnser.AliasStrategist.
+// It uses CNAMEs if any backend needs them, so that the same actions can be applied to every backend.
+func (a FanOut) AliasStrategy() dnser.AliasStrategy {
+	for _, backend := range a.backends {
+		if dnser.AliasStrategyOf(backend.Adapter) == dnser.CNAMERecords {
+			return dnser.CNAMERecords
+		}
+	}
+	return dnser.AliasRecords
+}
+
+func (a FanOut) backendIndex(name string) int {
+	for i, backend := range a.backends {
+		if backend.Name == name {
+			return i
+		}
+	}
+	return len(a.backends)
+}
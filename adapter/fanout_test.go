@@ -0,0 +1,187 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/flood4life/dnser"
+)
+
+// memoryAdapter is an in-memory dnser.Adapter used to test composite adapters.
+type memoryAdapter struct {
+	mu        sync.Mutex
+	records   []dnser.DNSRecord
+	processed [][]dnser.Action
+	calls     int
+	err       error
+}
+
+func (m *memoryAdapter) List(ctx context.Context) ([]dnser.DNSRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]dnser.DNSRecord(nil), m.records...), nil
+}
+
+func (m *memoryAdapter) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return m.err
+	}
+	for _, actions := range actionGroups {
+		m.records = applyActions(m.records, actions)
+		m.processed = append(m.processed, actions)
+	}
+	return nil
+}
+
+func TestFanOut_List(t *testing.T) {
+	primaryRecords := []dnser.DNSRecord{dnser.NewRecord("example.org.", "127.0.0.1")}
+	tests := []struct {
+		name      string
+		mode      ListMode
+		secondary []dnser.DNSRecord
+		wantErr   bool
+	}{{
+		name:      "primary only ignores divergence",
+		mode:      ListPrimary,
+		secondary: nil,
+		wantErr:   false,
+	}, {
+		name:      "consensus agrees",
+		mode:      ListConsensus,
+		secondary: primaryRecords,
+		wantErr:   false,
+	}, {
+		name:      "consensus diverges",
+		mode:      ListConsensus,
+		secondary: nil,
+		wantErr:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewFanOut(tt.mode, FailFast,
+				Backend{Name: "primary", Adapter: &memoryAdapter{records: primaryRecords}},
+				Backend{Name: "secondary", Adapter: &memoryAdapter{records: tt.secondary}},
+			)
+			got, err := a.List(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("List() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, primaryRecords) {
+				t.Errorf("List() got = %v, want %v", got, primaryRecords)
+			}
+			var divergence *DivergenceError
+			if tt.wantErr && (!errors.As(err, &divergence) || len(divergence.Missing) != 1) {
+				t.Errorf("List() error = %v, want DivergenceError with 1 missing record", err)
+			}
+		})
+	}
+}
+
+// blockingAdapter is a dnser.Adapter whose Process blocks until its context is cancelled.
+type blockingAdapter struct {
+	memoryAdapter
+}
+
+func (b *blockingAdapter) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestFanOut_Process(t *testing.T) {
+	groups := [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("example.org.", "127.0.0.1"),
+	}}, {{
+		Type:   dnser.Upsert,
+		Record: dnser.NewAliasRecord("foo.example.org.", "example.org."),
+	}}}
+	failure := errors.New("boom")
+
+	tests := []struct {
+		name      string
+		mode      FailureMode
+		primary   dnser.Adapter
+		secondary dnser.Adapter
+		wantErrs  []error
+	}{{
+		name:      "all good",
+		mode:      FailFast,
+		primary:   &memoryAdapter{},
+		secondary: &memoryAdapter{},
+		wantErrs:  nil,
+	}, {
+		name:      "fail fast cancels primary on secondary failure",
+		mode:      FailFast,
+		primary:   &blockingAdapter{},
+		secondary: &memoryAdapter{err: failure},
+		wantErrs:  []error{context.Canceled, failure},
+	}, {
+		name:      "fail primary continues on secondary failure",
+		mode:      FailPrimary,
+		primary:   &memoryAdapter{},
+		secondary: &memoryAdapter{err: failure},
+		wantErrs:  []error{failure},
+	}, {
+		name:      "fail primary cancels secondary on primary failure",
+		mode:      FailPrimary,
+		primary:   &memoryAdapter{err: failure},
+		secondary: &blockingAdapter{},
+		wantErrs:  []error{failure, context.Canceled},
+	}, {
+		name:      "best effort continues on primary failure",
+		mode:      BestEffort,
+		primary:   &memoryAdapter{err: failure},
+		secondary: &memoryAdapter{},
+		wantErrs:  []error{failure},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewFanOut(ListPrimary, tt.mode,
+				Backend{Name: "primary", Adapter: tt.primary},
+				Backend{Name: "secondary", Adapter: tt.secondary},
+			)
+			err := a.Process(context.Background(), groups)
+
+			var fanOutErr *FanOutError
+			if len(tt.wantErrs) == 0 && err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if len(tt.wantErrs) > 0 && (!errors.As(err, &fanOutErr) || len(fanOutErr.Errors) != len(tt.wantErrs)) {
+				t.Fatalf("Process() error = %v, want %d backend errors", err, len(tt.wantErrs))
+			}
+			for i, want := range tt.wantErrs {
+				if !errors.Is(fanOutErr.Errors[i], want) {
+					t.Errorf("Process() error %d = %v, want %v", i, fanOutErr.Errors[i], want)
+				}
+			}
+			for _, backend := range []dnser.Adapter{tt.primary, tt.secondary} {
+				m, ok := backend.(*memoryAdapter)
+				if !ok || m.err != nil {
+					continue
+				}
+				// every backend gets all groups in a single call
+				if m.calls != 1 || !reflect.DeepEqual(m.processed, groups) {
+					t.Errorf("backend processed %v in %d calls, want %v in 1", m.processed, m.calls, groups)
+				}
+			}
+		})
+	}
+}
+
+func TestFanOut_AliasStrategy(t *testing.T) {
+	route53Like := Backend{Name: "route53", Adapter: &memoryAdapter{}}
+	coreDNS := Backend{Name: "coredns", Adapter: NewCoreDNS(t.TempDir(), CoreDNSFile)}
+
+	if got := dnser.AliasStrategyOf(NewFanOut(ListPrimary, FailFast, route53Like)); got != dnser.AliasRecords {
+		t.Errorf("AliasStrategyOf() = %v, want AliasRecords", got)
+	}
+	if got := dnser.AliasStrategyOf(NewFanOut(ListPrimary, FailFast, route53Like, coreDNS)); got != dnser.CNAMERecords {
+		t.Errorf("AliasStrategyOf() = %v, want CNAMERecords", got)
+	}
+}
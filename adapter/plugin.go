@@ -0,0 +1,230 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+// PluginProtocolVersion is the version of the plugin protocol spoken by Plugin.
+const PluginProtocolVersion = 1
+
+// Plugin methods.
+const (
+	PluginList    = "list"
+	PluginProcess = "process"
+)
+
+// Plugin is an Adapter backed by an external executable.
+//
+// For every call the executable is started, a single PluginRequest is written
+// to its stdin as JSON and a single PluginResponse is read from its stdout.
+// Anything written to stderr is included in the error if the executable fails.
+// See PROTOCOL.md for the description of the protocol.
+type Plugin struct {
+	path string
+	args []string
+}
+
+// NewPlugin constructs a Plugin instance that runs the executable at path with args.
+func NewPlugin(path string, args ...string) Plugin {
+	return Plugin{
+		path: path,
+		args: args,
+	}
+}
+
+// PluginRequest is sent by dnser to the plugin.
+type PluginRequest struct {
+	Version int              `json:"version"`
+	Method  string           `json:"method"`
+	Actions [][]PluginAction `json:"actions,omitempty"`
+}
+
+// PluginResponse is sent by the plugin to dnser.
+type PluginResponse struct {
+	Records []PluginRecord `json:"records,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// PluginRecord is the JSON representation of dnser.DNSRecord.
+type PluginRecord struct {
+	Alias              bool             `json:"alias"`
+	Type               dnser.RecordType `json:"type,omitempty"`
+	Name               string           `json:"name"`
+	Target             string           `json:"target"`
+	TTL                int64            `json:"ttl,omitempty"`
+	TargetZoneID       string           `json:"targetZoneId,omitempty"`
+	IgnoreTargetHealth bool             `json:"ignoreTargetHealth,omitempty"`
+	Routing            *PluginRouting   `json:"routing,omitempty"`
+}
+
+// PluginRouting is the JSON representation of config.Routing.
+// Records with the simple routing policy have none.
+type PluginRouting struct {
+	Policy        config.RoutingPolicy `json:"policy,omitempty"`
+	SetIdentifier string               `json:"setIdentifier"`
+	Weight        int64                `json:"weight,omitempty"`
+	Region        string               `json:"region,omitempty"`
+	Failover      config.FailoverRole  `json:"failover,omitempty"`
+	GeoLocation   *PluginGeoLocation   `json:"geolocation,omitempty"`
+	HealthCheckID string               `json:"healthCheckId,omitempty"`
+}
+
+// PluginGeoLocation is the JSON representation of config.GeoLocation.
+type PluginGeoLocation struct {
+	ContinentCode   string `json:"continentCode,omitempty"`
+	CountryCode     string `json:"countryCode,omitempty"`
+	SubdivisionCode string `json:"subdivisionCode,omitempty"`
+}
+
+// PluginAction is the JSON representation of dnser.Action.
+type PluginAction struct {
+	Type   dnser.ActionType `json:"type"`
+	Record PluginRecord     `json:"record"`
+}
+
+// List asks the plugin for all DNS records it has access to.
+func (a Plugin) List(ctx context.Context) ([]dnser.DNSRecord, error) {
+	res, err := a.call(ctx, PluginRequest{
+		Version: PluginProtocolVersion,
+		Method:  PluginList,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]dnser.DNSRecord, len(res.Records))
+	for i, r := range res.Records {
+		records[i] = r.toDNSRecord()
+	}
+	return records, nil
+}
+
+// Process sends all action groups to the plugin in a single request.
+// The plugin is responsible for applying the groups in order.
+func (a Plugin) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	groups := make([][]PluginAction, len(actionGroups))
+	for i, actions := range actionGroups {
+		groups[i] = make([]PluginAction, len(actions))
+		for j, action := range actions {
+			groups[i][j] = PluginAction{
+				Type:   action.Type,
+				Record: pluginRecordFromDNSRecord(action.Record),
+			}
+		}
+	}
+
+	_, err := a.call(ctx, PluginRequest{
+		Version: PluginProtocolVersion,
+		Method:  PluginProcess,
+		Actions: groups,
+	})
+	return err
+}
+
+func (a Plugin) call(ctx context.Context, req PluginRequest) (PluginResponse, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return PluginResponse{}, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, a.path, a.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return PluginResponse{}, fmt.Errorf("plugin %s %s: %w: %s", a.path, req.Method, err, stderr.String())
+	}
+
+	var res PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return PluginResponse{}, fmt.Errorf("plugin %s %s: malformed response: %w", a.path, req.Method, err)
+	}
+	if res.Error != "" {
+		return PluginResponse{}, fmt.Errorf("plugin %s %s: %w", a.path, req.Method, errors.New(res.Error))
+	}
+	return res, nil
+}
+
+func pluginRecordFromDNSRecord(r dnser.DNSRecord) PluginRecord {
+	return PluginRecord{
+		Alias:              r.Alias,
+		Type:               r.Type,
+		Name:               string(r.Name),
+		Target:             string(r.Target),
+		TTL:                r.TTL,
+		TargetZoneID:       r.TargetZoneID,
+		IgnoreTargetHealth: r.IgnoreTargetHealth,
+		Routing:            pluginRoutingFromRouting(r.Routing),
+	}
+}
+
+func pluginRoutingFromRouting(r config.Routing) *PluginRouting {
+	if r == (config.Routing{}) {
+		return nil
+	}
+	routing := &PluginRouting{
+		Policy:        r.Policy,
+		SetIdentifier: r.SetIdentifier,
+		Weight:        r.Weight,
+		Region:        r.Region,
+		Failover:      r.Failover,
+		HealthCheckID: r.HealthCheckID,
+	}
+	if r.Geo != (config.GeoLocation{}) {
+		routing.GeoLocation = &PluginGeoLocation{
+			ContinentCode:   r.Geo.ContinentCode,
+			CountryCode:     r.Geo.CountryCode,
+			SubdivisionCode: r.Geo.SubdivisionCode,
+		}
+	}
+	return routing
+}
+
+func (r PluginRecord) toDNSRecord() dnser.DNSRecord {
+	recordType := r.Type
+	if recordType == "A" {
+		recordType = dnser.TypeA
+	}
+	return dnser.DNSRecord{
+		Alias:              r.Alias,
+		Type:               recordType,
+		Name:               config.Domain(r.Name),
+		Target:             config.Domain(r.Target),
+		TTL:                r.TTL,
+		TargetZoneID:       r.TargetZoneID,
+		IgnoreTargetHealth: r.IgnoreTargetHealth,
+		Routing:            r.Routing.toRouting(),
+	}
+}
+
+func (r *PluginRouting) toRouting() config.Routing {
+	if r == nil {
+		return config.Routing{}
+	}
+	routing := config.Routing{
+		Policy:        r.Policy,
+		SetIdentifier: r.SetIdentifier,
+		Weight:        r.Weight,
+		Region:        r.Region,
+		Failover:      r.Failover,
+		HealthCheckID: r.HealthCheckID,
+	}
+	if r.GeoLocation != nil {
+		routing.Geo = config.GeoLocation{
+			ContinentCode:   r.GeoLocation.ContinentCode,
+			CountryCode:     r.GeoLocation.CountryCode,
+			SubdivisionCode: r.GeoLocation.SubdivisionCode,
+		}
+	}
+	return routing
+}
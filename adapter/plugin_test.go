@@ -0,0 +1,136 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+// TestPluginHelperProcess is not a real test, it's the plugin executable used by TestPlugin.
+func TestPluginHelperProcess(t *testing.T) {
+	if os.Getenv("DNSER_PLUGIN_HELPER") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	var req PluginRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var res PluginResponse
+	switch req.Method {
+	case PluginList:
+		res.Records = []PluginRecord{{Alias: false, Name: "example.org.", Target: "127.0.0.1"}, {
+			Alias:        true,
+			Name:         "app.example.org.",
+			Target:       "my-lb-123.eu-west-1.elb.amazonaws.com.",
+			TargetZoneID: "Z32O12XQLNTSW2",
+			Routing:      &PluginRouting{Policy: config.WeightedRouting, SetIdentifier: "blue", Weight: 90},
+		}}
+	case PluginProcess:
+		if len(req.Actions) != 1 || req.Actions[0][0].Record.Name != "foo.example.org." {
+			res.Error = "unexpected actions"
+		}
+	default:
+		res.Error = "unknown method " + req.Method
+	}
+	json.NewEncoder(os.Stdout).Encode(res)
+}
+
+func helperPlugin(t *testing.T) Plugin {
+	os.Setenv("DNSER_PLUGIN_HELPER", "1")
+	t.Cleanup(func() { os.Unsetenv("DNSER_PLUGIN_HELPER") })
+	return NewPlugin(os.Args[0], "-test.run=TestPluginHelperProcess")
+}
+
+func TestPlugin_List(t *testing.T) {
+	got, err := helperPlugin(t).List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []dnser.DNSRecord{dnser.NewRecord("example.org.", "127.0.0.1"), {
+		Alias:        true,
+		Name:         "app.example.org.",
+		Target:       "my-lb-123.eu-west-1.elb.amazonaws.com.",
+		TargetZoneID: "Z32O12XQLNTSW2",
+		Routing:      config.Routing{Policy: config.WeightedRouting, SetIdentifier: "blue", Weight: 90},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() got = %v, want %v", got, want)
+	}
+}
+
+func TestPluginRecord_RoundTrip(t *testing.T) {
+	records := []dnser.DNSRecord{
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		{
+			Alias:              true,
+			Name:               "app.example.org.",
+			Target:             "d111111abcdef8.cloudfront.net.",
+			TargetZoneID:       "Z2FDTNDATAQYW2",
+			IgnoreTargetHealth: true,
+			Routing: config.Routing{
+				Policy:        config.GeolocationRouting,
+				SetIdentifier: "eu",
+				Geo:           config.GeoLocation{ContinentCode: "EU"},
+				HealthCheckID: "0a1b2c3d",
+			},
+		},
+		{
+			Name:    "app.example.org.",
+			Target:  "10.0.0.2",
+			Routing: config.Routing{Policy: config.FailoverRouting, SetIdentifier: "b", Failover: config.Secondary},
+		},
+	}
+	for _, want := range records {
+		data, err := json.Marshal(pluginRecordFromDNSRecord(want))
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var record PluginRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if got := record.toDNSRecord(); got != want {
+			t.Errorf("round trip of %s got = %+v, want %+v", data, got, want)
+		}
+	}
+}
+
+func TestPlugin_Process(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions [][]dnser.Action
+		wantErr bool
+	}{{
+		name: "all good",
+		actions: [][]dnser.Action{{{
+			Type:   dnser.Upsert,
+			Record: dnser.NewAliasRecord("foo.example.org.", "example.org."),
+		}}},
+		wantErr: false,
+	}, {
+		name: "plugin error",
+		actions: [][]dnser.Action{{{
+			Type:   dnser.Upsert,
+			Record: dnser.NewAliasRecord("bar.example.org.", "example.org."),
+		}}},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := helperPlugin(t).Process(context.Background(), tt.actions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Process() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
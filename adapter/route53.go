@@ -2,12 +2,11 @@ package adapter
 
 import (
 	"context"
+	"fmt"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 	"github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/flood4life/dnser"
@@ -19,34 +18,55 @@ const defaultTTL = 300 // 5 minutes
 
 // Route53 is an Adapter that's using AWS Route53.
 type Route53 struct {
-	client *route53.Client
-	zones  map[config.Domain]string // map TLDs to zone IDs
+	client throttledClient
+	zones  map[config.Domain]string // map zone names to zone IDs
+	// sharedZoneNames maps the names of several zones, e.g. of a public and a private zone,
+	// to the ID of another zone than zones does.
+	sharedZoneNames map[config.Domain]string
+	wait            WaitOptions
+
+	zoneFilter       ZoneFilter
+	rollback         bool
+	healthCheckOwner string
 }
 
 type hostedZone struct {
-	id   string
-	name config.Domain
+	id      string
+	name    config.Domain
+	private bool
 }
 
 // NewRoute53 constructs a Route53 instance from AWS access key and secret.
+//
+// Deprecated: NewRoute53 panics if the AWS config can't be loaded,
+// use NewRoute53WithOptions instead.
 func NewRoute53(id, secret string) Route53 {
-	cfg, err := awsConfig.LoadDefaultConfig(context.Background())
+	a, err := NewRoute53WithOptions(context.Background(),
+		WithStaticCredentials(id, secret),
+		WithRegion("eu-west-1"),
+	)
 	if err != nil {
 		panic(err)
 	}
-	creds := aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(
-		id, secret, "",
-	))
-	cfg.Credentials = creds
-	cfg.Region = "eu-west-1"
-	return NewRoute53FromSession(cfg)
+	return a
 }
 
 // NewRoute53FromSession could be used if a more detailed configuration of AWS Session is needed.
+// The Retryer of c is not used, the adapter retries throttled calls itself.
 func NewRoute53FromSession(c aws.Config) Route53 {
+	return newRoute53(route53.NewFromConfig(c, withoutClientRetries), route53Options{})
+}
+
+func newRoute53(client *route53.Client, o route53Options) Route53 {
 	return Route53{
-		client: route53.NewFromConfig(c),
-		zones:  make(map[config.Domain]string),
+		client:          newThrottledClient(client, o),
+		zones:           make(map[config.Domain]string),
+		sharedZoneNames: make(map[config.Domain]string),
+		wait:            o.wait,
+
+		zoneFilter:       o.zoneFilter,
+		rollback:         o.rollback,
+		healthCheckOwner: o.healthCheckOwner,
 	}
 }
 
@@ -60,17 +80,42 @@ func (a Route53) List(ctx context.Context) ([]dnser.DNSRecord, error) {
 	return flattenRecords(records), nil
 }
 
-func (a Route53) zoneIDFromDomain(domain config.Domain) string {
-	// TODO: handle cases when map is not initialized
-	id, ok := a.zones[domain]
-	if !ok {
-		panic("zone ID is unknown")
+// zoneIDForName returns the ID of the most specific known zone that hosts name.
+func (a Route53) zoneIDForName(name config.Domain) (string, error) {
+	best, id := config.Domain(""), ""
+	for zone, zoneID := range a.zones {
+		if isSubdomain(name, zone) && len(zone) > len(best) {
+			best, id = zone, zoneID
+		}
 	}
-	return id
+	if id == "" {
+		return "", fmt.Errorf("no hosted zone for %s", name)
+	}
+	if other, ok := a.sharedZoneNames[best]; ok {
+		return "", fmt.Errorf("%s is in both hosted zones %s and %s named %s, use a ZoneFilter to select one of them",
+			name, other, id, best)
+	}
+	return id, nil
+}
+
+// ensureZonesMap lists the hosted zones if List wasn't called before.
+func (a Route53) ensureZonesMap(ctx context.Context) error {
+	if len(a.zones) > 0 {
+		return nil
+	}
+	zones, err := a.listHostedZones(ctx)
+	if err != nil {
+		return err
+	}
+	a.initZonesMap(zones)
+	return nil
 }
 
 func (a Route53) initZonesMap(zones []hostedZone) {
 	for _, zone := range zones {
+		if id, ok := a.zones[zone.name]; ok && id != zone.id {
+			a.sharedZoneNames[zone.name] = id
+		}
 		a.zones[zone.name] = zone.id
 	}
 }
@@ -111,24 +156,6 @@ func (a Route53) recordsPerZone(ctx context.Context) ([][]dnser.DNSRecord, error
 	return records, nil
 }
 
-func (a Route53) listHostedZones(ctx context.Context) ([]hostedZone, error) {
-	zones := make([]hostedZone, 0)
-	paginator := route53.NewListHostedZonesPaginator(a.client, listZonesInput())
-	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, err
-		}
-		for _, zone := range output.HostedZones {
-			zones = append(zones, hostedZone{
-				id:   extractZoneID(*zone.Id),
-				name: config.Domain(*zone.Name),
-			})
-		}
-	}
-	return zones, nil
-}
-
 func (a Route53) listZoneRecords(ctx context.Context, zone hostedZone) ([]dnser.DNSRecord, error) {
 	records := make([]dnser.DNSRecord, 0)
 	paginator := NewListResourceRecordSetsPaginator(a.client, listZoneRecordsInput(zone.id))
@@ -138,19 +165,28 @@ func (a Route53) listZoneRecords(ctx context.Context, zone hostedZone) ([]dnser.
 			return nil, err
 		}
 		for _, recordSet := range output.ResourceRecordSets {
-			if recordSet.Type != types.RRTypeA {
+			if recordSet.AliasTarget != nil {
+				if recordSet.Type == types.RRTypeA {
+					records = append(records, a.aliasFromRecordSet(recordSet))
+				}
 				continue
 			}
-			if recordSet.AliasTarget != nil {
-				records = append(records, dnser.NewAliasRecord(
-					*recordSet.Name, *recordSet.AliasTarget.DNSName,
-				))
+			recordType, ok := recordTypeFromRRType(recordSet.Type)
+			if !ok {
 				continue
 			}
 			for _, resourceRecord := range recordSet.ResourceRecords {
-				records = append(records, dnser.NewRecord(
-					*recordSet.Name, *resourceRecord.Value,
-				))
+				record := dnser.DNSRecord{
+					Type:    recordType,
+					Name:    unescapeName(*recordSet.Name),
+					Target:  config.Domain(*resourceRecord.Value),
+					TTL:     aws.ToInt64(recordSet.TTL),
+					Routing: routingFromRecordSet(recordSet),
+				}
+				if recordType == dnser.TypeTXT {
+					record.Target = config.Domain(unquoteTXT(*resourceRecord.Value))
+				}
+				records = append(records, record)
 			}
 		}
 	}
@@ -169,66 +205,104 @@ func listZoneRecordsInput(zoneID string) *route53.ListResourceRecordSetsInput {
 
 // Process creates and deletes DNS records.
 func (a Route53) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	if err := a.ensureZonesMap(ctx); err != nil {
+		return err
+	}
+	if a.rollback {
+		return a.processWithRollback(ctx, actionGroups)
+	}
+
+	_, err := a.processGroups(ctx, actionGroups)
+	return err
+}
+
+// changeSet is a batch of actions on the records of a zone, applied atomically by Route53.
+type changeSet struct {
+	zoneID  string
+	actions []dnser.Action
+}
+
+// processGroups applies the groups in order and returns the change sets
+// that Route53 accepted, even if it fails.
+func (a Route53) processGroups(ctx context.Context, actionGroups [][]dnser.Action) ([]changeSet, error) {
+	applied := make([]changeSet, 0)
+	var mu sync.Mutex
+
 	for _, actions := range actionGroups {
+		sets, err := a.changeSets(actions)
+		if err != nil {
+			return applied, err
+		}
+
 		g, gCtx := errgroup.WithContext(ctx)
-		inputs := a.changeSetInputs(actions)
-		for _, input := range inputs {
-			a.processChangeSet(gCtx, g, input)
+		for _, set := range sets {
+			set := set
+			g.Go(func() error {
+				changeID, err := a.submitChangeSet(gCtx, set)
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				applied = append(applied, set)
+				mu.Unlock()
+				return a.wait.wait(gCtx, a.client, set.zoneID, changeID)
+			})
 		}
 
 		if err := g.Wait(); err != nil {
-			return err
+			return applied, err
 		}
 	}
 
-	return nil
+	return applied, nil
 }
 
-func (a Route53) processChangeSet(ctx context.Context, g *errgroup.Group, input *route53.ChangeResourceRecordSetsInput) {
-	g.Go(func() error {
-		res, err := a.client.ChangeResourceRecordSets(ctx, input)
-		if err != nil {
-			return err
-		}
-		for {
-			// wait for changes to become active
-			change, err := a.client.GetChange(ctx, &route53.GetChangeInput{Id: res.ChangeInfo.Id})
-			if err != nil {
-				return err
-			}
-			if change.ChangeInfo.Status == types.ChangeStatusInsync {
-				break
-			}
-			time.Sleep(time.Second)
-		}
-		return nil
+// submitChangeSet sends the change set to Route53 and returns the ID of the change.
+func (a Route53) submitChangeSet(ctx context.Context, set changeSet) (string, error) {
+	res, err := a.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch:  a.changeBatch(set.actions),
+		HostedZoneId: aws.String(set.zoneID),
 	})
+	if err != nil {
+		return "", err
+	}
+	return extractZoneID(*res.ChangeInfo.Id), nil
 }
 
-func (a Route53) changeSetInputs(actions []dnser.Action) []*route53.ChangeResourceRecordSetsInput {
-	result := make([]*route53.ChangeResourceRecordSetsInput, 0)
+func (a Route53) changeSets(actions []dnser.Action) ([]changeSet, error) {
+	result := make([]changeSet, 0)
 
-	groupedActions := a.groupActionsPerTLD(actions)
+	groupedActions, err := a.groupActionsPerZone(actions)
+	if err != nil {
+		return nil, err
+	}
 	for zoneID, zoneActions := range groupedActions {
-		result = append(result, &route53.ChangeResourceRecordSetsInput{
-			ChangeBatch:  a.changeBatch(zoneActions),
-			HostedZoneId: &zoneID,
-		})
+		for _, batch := range splitChangeBatch(zoneActions, route53BatchLimits) {
+			result = append(result, changeSet{zoneID: zoneID, actions: batch})
+		}
 	}
 
-	return result
+	return result, nil
 }
 
-func (a Route53) groupActionsPerTLD(actions []dnser.Action) map[string][]dnser.Action {
+// groupActionsPerZone groups the actions by the ID of the zone that hosts the record.
+// The target zone IDs of alias records are resolved on the way.
+func (a Route53) groupActionsPerZone(actions []dnser.Action) (map[string][]dnser.Action, error) {
 	result := make(map[string][]dnser.Action)
 	for _, action := range actions {
-		zoneID := a.zoneIDFromDomain(action.Record.NameZone())
-		if _, ok := result[zoneID]; !ok {
-			result[zoneID] = make([]dnser.Action, 0)
+		zoneID, err := a.zoneIDForName(action.Record.Name)
+		if err != nil {
+			return nil, err
+		}
+		if action.Record.Alias && action.Record.TargetZoneID == "" {
+			action.Record.TargetZoneID, err = a.zoneIDForName(action.Record.Target)
+			if err != nil {
+				return nil, fmt.Errorf("alias %s: %w", action.Record.Name, err)
+			}
 		}
 		result[zoneID] = append(result[zoneID], action)
 	}
-	return result
+	return result, nil
 }
 
 func (a Route53) changeBatch(actions []dnser.Action) *types.ChangeBatch {
@@ -237,14 +311,28 @@ func (a Route53) changeBatch(actions []dnser.Action) *types.ChangeBatch {
 	}
 }
 
+// changeActions converts the actions into changes.
+// The actions on records of the same key become a single change of the record set with all their values.
 func (a Route53) changeActions(actions []dnser.Action) []types.Change {
-	result := make([]types.Change, len(actions))
+	type changeKey struct {
+		actionType dnser.ActionType
+		recordKey  dnser.RecordKey
+	}
+	result := make([]types.Change, 0, len(actions))
+	index := make(map[changeKey]int, len(actions))
 
-	for i, action := range actions {
-		result[i] = types.Change{
+	for _, action := range actions {
+		key := changeKey{actionType: action.Type, recordKey: action.Record.Key()}
+		if i, ok := index[key]; ok && !action.Record.Alias {
+			recordSet := result[i].ResourceRecordSet
+			recordSet.ResourceRecords = append(recordSet.ResourceRecords, resourceRecord(action.Record))
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, types.Change{
 			Action:            actionFromActionType(action.Type),
 			ResourceRecordSet: a.resourceRecordSet(action.Record),
-		}
+		})
 	}
 
 	return result
@@ -262,29 +350,91 @@ func actionFromActionType(actionType dnser.ActionType) types.ChangeAction {
 }
 
 func (a Route53) resourceRecordSet(record dnser.DNSRecord) *types.ResourceRecordSet {
-	if record.Alias {
-		return a.aliasRecord(record)
-	}
-	return &types.ResourceRecordSet{
+	recordSet := &types.ResourceRecordSet{
 		Name:            recordName(record),
-		ResourceRecords: []types.ResourceRecord{{Value: recordTarget(record)}},
-		TTL:             aws.Int64(defaultTTL),
-		Type:            types.RRTypeA,
+		ResourceRecords: []types.ResourceRecord{resourceRecord(record)},
+		TTL:             aws.Int64(recordTTL(record)),
+		Type:            types.RRType(record.Type.String()),
+	}
+	if record.Alias {
+		recordSet = a.aliasRecord(record)
 	}
+	applyRouting(recordSet, record.Routing)
+	return recordSet
 }
 
 func (a Route53) aliasRecord(record dnser.DNSRecord) *types.ResourceRecordSet {
 	return &types.ResourceRecordSet{
 		AliasTarget: &types.AliasTarget{
 			DNSName:              recordTarget(record),
-			EvaluateTargetHealth: true,
-			HostedZoneId:         aws.String(a.zoneIDFromDomain(record.NameZone())),
+			EvaluateTargetHealth: !record.IgnoreTargetHealth,
+			HostedZoneId:         aws.String(record.TargetZoneID),
 		},
 		Name: recordName(record),
 		Type: types.RRTypeA,
 	}
 }
 
+// aliasFromRecordSet converts an alias record set.
+// The target zone ID is omitted if it's the zone that hosts the target,
+// the same way it's omitted for the aliases of the config trees.
+func (a Route53) aliasFromRecordSet(recordSet types.ResourceRecordSet) dnser.DNSRecord {
+	record := dnser.NewAliasRecord(string(unescapeName(*recordSet.Name)), string(unescapeName(*recordSet.AliasTarget.DNSName)))
+	record.IgnoreTargetHealth = !recordSet.AliasTarget.EvaluateTargetHealth
+	record.Routing = routingFromRecordSet(recordSet)
+	zoneID := aws.ToString(recordSet.AliasTarget.HostedZoneId)
+	if targetZoneID, err := a.zoneIDForName(record.Target); err != nil || zoneID != targetZoneID {
+		record.TargetZoneID = zoneID
+	}
+	return record
+}
+
+// NormalizeAliasTargets removes the hosted zone IDs of the alias targets of cfg
+// that are the ID of the zone hosting their DNS name, e.g. an explicit hostedZoneId of a zone target,
+// the same way List omits them, so that the alias isn't replaced on every run.
+func (a Route53) NormalizeAliasTargets(ctx context.Context, cfg config.Config) (config.Config, error) {
+	if err := a.ensureZonesMap(ctx); err != nil {
+		return config.Config{}, err
+	}
+	items := make([]config.Item, len(cfg.Config))
+	for i, item := range cfg.Config {
+		if item.Alias != nil && item.Alias.HostedZoneID != "" {
+			if zoneID, err := a.zoneIDForName(item.Alias.DNSName); err == nil && zoneID == item.Alias.HostedZoneID {
+				target := *item.Alias
+				target.HostedZoneID = ""
+				item.Alias = &target
+			}
+		}
+		items[i] = item
+	}
+	cfg.Config = items
+	return cfg, nil
+}
+
+// recordTypeFromRRType returns the type of the records dnser manages.
+func recordTypeFromRRType(t types.RRType) (dnser.RecordType, bool) {
+	switch t {
+	case types.RRTypeA:
+		return dnser.TypeA, true
+	case types.RRTypeAaaa:
+		return dnser.TypeAAAA, true
+	case types.RRTypeCname:
+		return dnser.TypeCNAME, true
+	case types.RRTypeTxt:
+		return dnser.TypeTXT, true
+	case types.RRTypeMx:
+		return dnser.TypeMX, true
+	case types.RRTypeCaa:
+		return dnser.TypeCAA, true
+	case types.RRTypeSrv:
+		return dnser.TypeSRV, true
+	case types.RRTypePtr:
+		return dnser.TypePTR, true
+	default:
+		return "", false
+	}
+}
+
 func recordName(r dnser.DNSRecord) *string {
 	return aws.String(string(r.Name))
 }
@@ -293,6 +443,24 @@ func recordTarget(r dnser.DNSRecord) *string {
 	return aws.String(string(r.Target))
 }
 
+// unescapeName reverts the octal escapes of the names Route53 lists,
+// e.g. the asterisk of a wildcard is listed as \052.
+func unescapeName(name string) config.Domain {
+	if !strings.Contains(name, `\`) {
+		return config.Domain(name)
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) && isOctal(name[i+1:i+4]) {
+			b.WriteByte((name[i+1]-'0')<<6 | (name[i+2]-'0')<<3 | (name[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+	return config.Domain(b.String())
+}
+
 func extractZoneID(response string) string {
 	// zone ID from aws response looks like "/hostedzone/Z2H9GA7I9LH893",
 	// but it expects the input in "Z2H9GA7I9LH893" format
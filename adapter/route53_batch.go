@@ -0,0 +1,81 @@
+package adapter
+
+import (
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+// batchLimits are the limits of a single ChangeResourceRecordSets request.
+// An UPSERT counts twice towards both of them.
+type batchLimits struct {
+	records    int // ResourceRecord elements
+	valueChars int // characters in all Value elements
+}
+
+// route53BatchLimits are the limits documented by AWS.
+var route53BatchLimits = batchLimits{
+	records:    1000,
+	valueChars: 32000,
+}
+
+// splitChangeBatch splits the actions of a zone into batches that respect limits.
+// All actions on the same record name are kept in the same batch,
+// so that a DELETE and an UPSERT of a record are applied atomically.
+// The order of the actions is preserved.
+func splitChangeBatch(actions []dnser.Action, limits batchLimits) [][]dnser.Action {
+	batches := make([][]dnser.Action, 0)
+	current := make([]dnser.Action, 0)
+	records, chars := 0, 0
+
+	for _, unit := range groupActionsByName(actions) {
+		unitRecords, unitChars := batchCost(unit)
+		if len(current) > 0 && (records+unitRecords > limits.records || chars+unitChars > limits.valueChars) {
+			batches = append(batches, current)
+			current = make([]dnser.Action, 0)
+			records, chars = 0, 0
+		}
+		// a unit that exceeds the limits on its own is sent as is,
+		// so Route53 reports the error for that record
+		current = append(current, unit...)
+		records += unitRecords
+		chars += unitChars
+	}
+
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// groupActionsByName groups the actions by record name
+// in the order the names first appear.
+func groupActionsByName(actions []dnser.Action) [][]dnser.Action {
+	index := make(map[config.Domain]int)
+	result := make([][]dnser.Action, 0)
+	for _, action := range actions {
+		i, ok := index[action.Record.Name]
+		if !ok {
+			i = len(result)
+			index[action.Record.Name] = i
+			result = append(result, make([]dnser.Action, 0, 1))
+		}
+		result[i] = append(result[i], action)
+	}
+	return result
+}
+
+func batchCost(actions []dnser.Action) (int, int) {
+	records, chars := 0, 0
+	for _, action := range actions {
+		weight := 1
+		if action.Type == dnser.Upsert {
+			weight = 2
+		}
+		records += weight
+		if !action.Record.Alias {
+			// the value as sent, e.g. a TXT value with its quotes and escapes
+			chars += weight * len(*resourceRecord(action.Record).Value)
+		}
+	}
+	return records, chars
+}
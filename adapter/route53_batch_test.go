@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flood4life/dnser"
+)
+
+func TestSplitChangeBatch(t *testing.T) {
+	deleteFoo := dnser.Action{Type: dnser.Delete, Record: dnser.NewRecord("foo.example.org.", "10.0.0.1")}
+	upsertFoo := dnser.Action{Type: dnser.Upsert, Record: dnser.NewRecord("foo.example.org.", "10.0.0.2")}
+	upsertBar := dnser.Action{Type: dnser.Upsert, Record: dnser.NewRecord("bar.example.org.", "10.0.0.3")}
+	aliasBaz := dnser.Action{Type: dnser.Upsert, Record: dnser.NewAliasRecord("baz.example.org.", "example.org.")}
+	// 8 characters of text, 12 once quoted and escaped
+	txtFoo := dnser.Action{Type: dnser.Upsert, Record: dnser.DNSRecord{Type: dnser.TypeTXT, Name: "foo.example.org.", Target: `say "hi"`}}
+	txtBar := dnser.Action{Type: dnser.Upsert, Record: dnser.DNSRecord{Type: dnser.TypeTXT, Name: "bar.example.org.", Target: `say "hi"`}}
+
+	type args struct {
+		actions []dnser.Action
+		limits  batchLimits
+	}
+	tests := []struct {
+		name string
+		args args
+		want [][]dnser.Action
+	}{{
+		name: "fits into a single batch",
+		args: args{
+			actions: []dnser.Action{deleteFoo, upsertFoo, upsertBar},
+			limits:  route53BatchLimits,
+		},
+		want: [][]dnser.Action{{deleteFoo, upsertFoo, upsertBar}},
+	}, {
+		name: "split by record count keeps pairs together",
+		args: args{
+			actions: []dnser.Action{deleteFoo, upsertBar, upsertFoo, aliasBaz},
+			limits:  batchLimits{records: 4, valueChars: 1000},
+		},
+		want: [][]dnser.Action{{deleteFoo, upsertFoo}, {upsertBar, aliasBaz}},
+	}, {
+		name: "split by value characters",
+		args: args{
+			actions: []dnser.Action{upsertFoo, upsertBar},
+			limits:  batchLimits{records: 1000, valueChars: 20},
+		},
+		want: [][]dnser.Action{{upsertFoo}, {upsertBar}},
+	}, {
+		name: "split by quoted TXT value characters",
+		args: args{
+			actions: []dnser.Action{txtFoo, txtBar},
+			limits:  batchLimits{records: 1000, valueChars: 40},
+		},
+		want: [][]dnser.Action{{txtFoo}, {txtBar}},
+	}, {
+		name: "oversized unit is sent on its own",
+		args: args{
+			actions: []dnser.Action{upsertBar, deleteFoo, upsertFoo},
+			limits:  batchLimits{records: 2, valueChars: 1000},
+		},
+		want: [][]dnser.Action{{upsertBar}, {deleteFoo, upsertFoo}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitChangeBatch(tt.args.actions, tt.args.limits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitChangeBatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
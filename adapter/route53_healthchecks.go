@@ -0,0 +1,253 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/flood4life/dnser/config"
+)
+
+// The caller reference of a managed health check is "dnser/<name>/<timestamp>",
+// or "dnser:<owner>/<name>/<timestamp>" with WithHealthCheckOwner.
+const (
+	healthCheckReferencePrefix      = "dnser/"
+	ownedHealthCheckReferencePrefix = "dnser:"
+
+	// maxCallerReferenceLength is Route53's limit of the caller reference.
+	maxCallerReferenceLength = 64
+)
+
+// WithHealthCheckOwner namespaces the health checks the adapter manages, so that
+// several configs in one account, e.g. one per team, don't replace or prune each other's checks.
+// The owner must not contain a slash. Without it the adapter manages the health checks
+// created without an owner.
+func WithHealthCheckOwner(owner string) Route53Option {
+	return func(o *route53Options) {
+		o.healthCheckOwner = owner
+	}
+}
+
+type managedHealthCheck struct {
+	id        string
+	name      string
+	reference string
+	version   int64
+	config    types.HealthCheckConfig
+}
+
+// EnsureHealthChecks creates and updates the health checks, so that they match the config.
+// It returns the IDs of the health checks by name, to be used with config.Config.WithHealthCheckIDs.
+//
+// Health checks are recognized by the caller reference dnser creates them with,
+// so health checks created by other tools or for other owners are never touched.
+// A health check whose type or interval changed is replaced by a new one,
+// the old one is left for PruneHealthChecks.
+func (a Route53) EnsureHealthChecks(ctx context.Context, checks []config.HealthCheck) (map[string]string, error) {
+	for _, hc := range checks {
+		if reference := healthCheckReference(a.healthCheckOwner, hc.Name, time.Now()); len(reference) > maxCallerReferenceLength {
+			return nil, fmt.Errorf("health check %s: caller reference %s is longer than %d characters",
+				hc.Name, reference, maxCallerReferenceLength)
+		}
+	}
+
+	existing, err := a.listManagedHealthChecks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]managedHealthCheck, len(existing))
+	for _, hc := range existing {
+		byName[hc.name] = hc
+	}
+
+	ids := make(map[string]string, len(checks))
+	for _, hc := range checks {
+		want := healthCheckConfig(hc)
+		have, ok := byName[hc.Name]
+
+		switch {
+		case !ok, needsHealthCheckReplace(have.config, want):
+			ids[hc.Name], err = a.createHealthCheck(ctx, hc.Name, want)
+		case needsHealthCheckUpdate(have.config, want):
+			ids[hc.Name] = have.id
+			err = a.updateHealthCheck(ctx, have, want)
+		default:
+			ids[hc.Name] = have.id
+		}
+		if err != nil {
+			return nil, fmt.Errorf("health check %s: %w", hc.Name, err)
+		}
+	}
+	return ids, nil
+}
+
+// PruneHealthChecks deletes the health checks of the adapter's owner whose IDs are not in keep,
+// the result of EnsureHealthChecks.
+// Call it after the records referring to them were processed.
+func (a Route53) PruneHealthChecks(ctx context.Context, keep map[string]string) error {
+	existing, err := a.listManagedHealthChecks(ctx)
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, id := range keep {
+		kept[id] = true
+	}
+	for _, hc := range existing {
+		if kept[hc.id] {
+			continue
+		}
+		if err := a.deleteHealthCheck(ctx, hc.id); err != nil {
+			return fmt.Errorf("health check %s: %w", hc.name, err)
+		}
+	}
+	return nil
+}
+
+// listManagedHealthChecks lists the health checks created by dnser for the adapter's owner, oldest first.
+func (a Route53) listManagedHealthChecks(ctx context.Context) ([]managedHealthCheck, error) {
+	result := make([]managedHealthCheck, 0)
+	input := &route53.ListHealthChecksInput{}
+	for {
+		output, err := a.client.ListHealthChecks(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, hc := range output.HealthChecks {
+			name, ok := healthCheckNameFromReference(a.healthCheckOwner, aws.ToString(hc.CallerReference))
+			if !ok || hc.HealthCheckConfig == nil {
+				continue
+			}
+			result = append(result, managedHealthCheck{
+				id:        aws.ToString(hc.Id),
+				name:      name,
+				reference: aws.ToString(hc.CallerReference),
+				version:   aws.ToInt64(hc.HealthCheckVersion),
+				config:    *hc.HealthCheckConfig,
+			})
+		}
+		if !output.IsTruncated {
+			break
+		}
+		input.Marker = output.NextMarker
+	}
+
+	// the caller reference ends with the creation time
+	sort.Slice(result, func(i, j int) bool {
+		return referenceTime(result[i].reference) < referenceTime(result[j].reference)
+	})
+	return result, nil
+}
+
+func (a Route53) createHealthCheck(ctx context.Context, name string, cfg types.HealthCheckConfig) (string, error) {
+	output, err := a.client.CreateHealthCheck(ctx, &route53.CreateHealthCheckInput{
+		CallerReference:   aws.String(healthCheckReference(a.healthCheckOwner, name, time.Now())),
+		HealthCheckConfig: &cfg,
+	})
+	if err != nil {
+		return "", err
+	}
+	id := aws.ToString(output.HealthCheck.Id)
+
+	// the Name tag is what the AWS console shows for the health check
+	_, err = a.client.ChangeTagsForResource(ctx, &route53.ChangeTagsForResourceInput{
+		ResourceId:   aws.String(id),
+		ResourceType: types.TagResourceTypeHealthcheck,
+		AddTags:      []types.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+	})
+	return id, err
+}
+
+func (a Route53) updateHealthCheck(ctx context.Context, have managedHealthCheck, want types.HealthCheckConfig) error {
+	input := &route53.UpdateHealthCheckInput{
+		HealthCheckId:            aws.String(have.id),
+		HealthCheckVersion:       aws.Int64(have.version),
+		IPAddress:                want.IPAddress,
+		FullyQualifiedDomainName: want.FullyQualifiedDomainName,
+		Port:                     want.Port,
+		ResourcePath:             want.ResourcePath,
+		FailureThreshold:         want.FailureThreshold,
+	}
+	if want.ResourcePath == nil && have.config.ResourcePath != nil {
+		input.ResetElements = append(input.ResetElements, types.ResettableElementNameResourcePath)
+	}
+	if want.FullyQualifiedDomainName == nil && have.config.FullyQualifiedDomainName != nil {
+		input.ResetElements = append(input.ResetElements, types.ResettableElementNameFullyQualifiedDomainName)
+	}
+	_, err := a.client.UpdateHealthCheck(ctx, input)
+	return err
+}
+
+func (a Route53) deleteHealthCheck(ctx context.Context, id string) error {
+	_, err := a.client.DeleteHealthCheck(ctx, &route53.DeleteHealthCheckInput{HealthCheckId: aws.String(id)})
+	return err
+}
+
+func healthCheckConfig(hc config.HealthCheck) types.HealthCheckConfig {
+	cfg := types.HealthCheckConfig{
+		Type:             types.HealthCheckType(hc.Type),
+		Port:             aws.Int32(hc.Port),
+		RequestInterval:  aws.Int32(hc.Interval),
+		FailureThreshold: aws.Int32(hc.FailureThreshold),
+		IPAddress:        optionalString(string(hc.IP)),
+		ResourcePath:     optionalString(hc.Path),
+	}
+	if hc.FQDN != "" {
+		cfg.FullyQualifiedDomainName = aws.String(hostName(hc.FQDN))
+	}
+	return cfg
+}
+
+// needsHealthCheckReplace returns whether the health check has to be recreated,
+// because the differing fields can't be updated.
+func needsHealthCheckReplace(have, want types.HealthCheckConfig) bool {
+	return have.Type != want.Type ||
+		aws.ToInt32(have.RequestInterval) != aws.ToInt32(want.RequestInterval) ||
+		// an IP address can't be removed from a health check
+		(have.IPAddress != nil && want.IPAddress == nil)
+}
+
+func needsHealthCheckUpdate(have, want types.HealthCheckConfig) bool {
+	return aws.ToString(have.IPAddress) != aws.ToString(want.IPAddress) ||
+		aws.ToString(have.FullyQualifiedDomainName) != aws.ToString(want.FullyQualifiedDomainName) ||
+		aws.ToInt32(have.Port) != aws.ToInt32(want.Port) ||
+		aws.ToString(have.ResourcePath) != aws.ToString(want.ResourcePath) ||
+		aws.ToInt32(have.FailureThreshold) != aws.ToInt32(want.FailureThreshold)
+}
+
+func healthCheckReference(owner, name string, now time.Time) string {
+	return fmt.Sprintf("%s%s/%d", healthCheckReferencePrefixOf(owner), name, now.UnixNano())
+}
+
+func healthCheckReferencePrefixOf(owner string) string {
+	if owner == "" {
+		return healthCheckReferencePrefix
+	}
+	return ownedHealthCheckReferencePrefix + owner + "/"
+}
+
+// healthCheckNameFromReference returns the name of a health check of the owner.
+func healthCheckNameFromReference(owner, reference string) (string, bool) {
+	prefix := healthCheckReferencePrefixOf(owner)
+	if !strings.HasPrefix(reference, prefix) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(reference, prefix)
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+func referenceTime(reference string) int64 {
+	created, _ := strconv.ParseInt(reference[strings.LastIndex(reference, "/")+1:], 10, 64)
+	return created
+}
@@ -0,0 +1,97 @@
+package adapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flood4life/dnser/config"
+)
+
+func TestHealthCheckReference(t *testing.T) {
+	reference := healthCheckReference("", "app/blue", time.Unix(0, 42))
+	name, ok := healthCheckNameFromReference("", reference)
+	if !ok || name != "app/blue" {
+		t.Errorf("healthCheckNameFromReference(%q) = %q, %v, want app/blue, true", reference, name, ok)
+	}
+	if got := referenceTime(reference); got != 42 {
+		t.Errorf("referenceTime(%q) = %d, want 42", reference, got)
+	}
+	if _, ok := healthCheckNameFromReference("", "terraform-2021"); ok {
+		t.Error("healthCheckNameFromReference() recognized a foreign health check")
+	}
+}
+
+func TestHealthCheckReference_owner(t *testing.T) {
+	tests := []struct {
+		owner, readBy string
+		want          bool
+	}{
+		{owner: "web", readBy: "web", want: true},
+		{owner: "web", readBy: "shop", want: false},
+		{owner: "web", readBy: "", want: false},
+		{owner: "", readBy: "web", want: false},
+	}
+	for _, tt := range tests {
+		reference := healthCheckReference(tt.owner, "app", time.Unix(0, 42))
+		name, ok := healthCheckNameFromReference(tt.readBy, reference)
+		if ok != tt.want || (ok && name != "app") {
+			t.Errorf("healthCheckNameFromReference(%q, %q) = %q, %v, want %v", tt.readBy, reference, name, ok, tt.want)
+		}
+	}
+}
+
+func TestRoute53_EnsureHealthChecksReferenceLength(t *testing.T) {
+	a := Route53{healthCheckOwner: strings.Repeat("o", 30)}
+	_, err := a.EnsureHealthChecks(context.Background(), []config.HealthCheck{{Name: strings.Repeat("n", 20)}})
+	if err == nil || !strings.Contains(err.Error(), "64") {
+		t.Errorf("EnsureHealthChecks() error = %v, want the caller reference limit", err)
+	}
+}
+
+func TestHealthCheckChanges(t *testing.T) {
+	base := config.HealthCheck{
+		Name: "app", Type: config.HTTPS, FQDN: "app.example.org.",
+		Port: 443, Path: "/health", Interval: 30, FailureThreshold: 3,
+	}
+	tests := []struct {
+		name        string
+		change      func(hc *config.HealthCheck)
+		wantReplace bool
+		wantUpdate  bool
+	}{{
+		name:   "unchanged",
+		change: func(hc *config.HealthCheck) {},
+	}, {
+		name:       "path",
+		change:     func(hc *config.HealthCheck) { hc.Path = "/ready" },
+		wantUpdate: true,
+	}, {
+		name:       "failure threshold",
+		change:     func(hc *config.HealthCheck) { hc.FailureThreshold = 5 },
+		wantUpdate: true,
+	}, {
+		name:        "interval",
+		change:      func(hc *config.HealthCheck) { hc.Interval = 10 },
+		wantReplace: true,
+	}, {
+		name:        "type",
+		change:      func(hc *config.HealthCheck) { hc.Type = config.HTTP },
+		wantReplace: true,
+		wantUpdate:  false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base
+			tt.change(&changed)
+			have, want := healthCheckConfig(base), healthCheckConfig(changed)
+			if got := needsHealthCheckReplace(have, want); got != tt.wantReplace {
+				t.Errorf("needsHealthCheckReplace() = %v, want %v", got, tt.wantReplace)
+			}
+			if got := needsHealthCheckUpdate(have, want); got != tt.wantUpdate {
+				t.Errorf("needsHealthCheckUpdate() = %v, want %v", got, tt.wantUpdate)
+			}
+		})
+	}
+}
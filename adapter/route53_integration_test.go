@@ -0,0 +1,617 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+	"github.com/flood4life/dnser/internal/route53fake"
+	"github.com/flood4life/dnser/massager"
+)
+
+// newFakeRoute53 starts a fake Route53 API and returns an adapter talking to it.
+func newFakeRoute53(t *testing.T, opts ...Route53Option) (*route53fake.Server, Route53) {
+	t.Helper()
+	server := route53fake.NewServer()
+	t.Cleanup(server.Close)
+	return newFakeRoute53WithServer(t, server, opts...)
+}
+
+// newFakeRoute53WithServer returns a new adapter talking to an existing fake Route53 API.
+func newFakeRoute53WithServer(t *testing.T, server *route53fake.Server, opts ...Route53Option) (*route53fake.Server, Route53) {
+	t.Helper()
+
+	opts = append([]Route53Option{
+		WithStaticCredentials("id", "secret"),
+		WithEndpoint(server.URL),
+		WithWait(WaitOptions{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		WithRateLimiter(NewRateLimiter(1000)),
+	}, opts...)
+	a, err := NewRoute53WithOptions(context.Background(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, a
+}
+
+func sortRecords(records []dnser.DNSRecord) []dnser.DNSRecord {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		if records[i].Type != records[j].Type {
+			return records[i].Type < records[j].Type
+		}
+		if records[i].Target != records[j].Target {
+			return records[i].Target < records[j].Target
+		}
+		return records[i].Routing.SetIdentifier < records[j].Routing.SetIdentifier
+	})
+	return records
+}
+
+// withDefaultTTL sets the TTL that the adapter writes for the records without one.
+func withDefaultTTL(records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, len(records))
+	for i, r := range records {
+		if !r.Alias && r.TTL == 0 {
+			r.TTL = defaultTTL
+		}
+		result[i] = r
+	}
+	return result
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestRoute53_ListPaginated(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.PageSize = 2
+	zoneID := server.AddZone("example.org", false)
+	server.AddRecordSets(zoneID,
+		route53fake.ResourceRecordSet{
+			Name: "example.org.", Type: "A", TTL: int64Ptr(300),
+			ResourceRecords: []route53fake.ResourceRecord{{Value: "127.0.0.1"}},
+		},
+		route53fake.ResourceRecordSet{
+			Name: "www.example.org.", Type: "A",
+			AliasTarget: &route53fake.AliasTarget{HostedZoneID: zoneID, DNSName: "example.org.", EvaluateTargetHealth: true},
+		},
+		route53fake.ResourceRecordSet{
+			Name: "app.example.org.", Type: "A", SetIdentifier: "blue", Weight: int64Ptr(90), TTL: int64Ptr(300),
+			ResourceRecords: []route53fake.ResourceRecord{{Value: "10.0.0.1"}},
+		},
+		route53fake.ResourceRecordSet{
+			Name: "app.example.org.", Type: "A", SetIdentifier: "green", Weight: int64Ptr(10), TTL: int64Ptr(300),
+			ResourceRecords: []route53fake.ResourceRecord{{Value: "10.0.0.2"}},
+		},
+		route53fake.ResourceRecordSet{
+			Name: "example.org.", Type: "TXT", TTL: int64Ptr(300),
+			ResourceRecords: []route53fake.ResourceRecord{{Value: `"v=spf1 -all"`}},
+		},
+	)
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blue := dnser.NewRecord("app.example.org.", "10.0.0.1")
+	blue.Routing = config.Routing{Policy: config.WeightedRouting, SetIdentifier: "blue", Weight: 90}
+	green := dnser.NewRecord("app.example.org.", "10.0.0.2")
+	green.Routing = config.Routing{Policy: config.WeightedRouting, SetIdentifier: "green", Weight: 10}
+	want := []dnser.DNSRecord{
+		blue,
+		green,
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		{Type: dnser.TypeTXT, Name: "example.org.", Target: "v=spf1 -all", TTL: 300},
+		dnser.NewAliasRecord("www.example.org.", "example.org."),
+	}
+	if got = sortRecords(got); !reflect.DeepEqual(got, withDefaultTTL(want)) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
+
+func TestRoute53_ProcessRoundTrip(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.AddZone("example.org", false)
+	server.AddZone("example.com", false)
+
+	records := []dnser.DNSRecord{
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		dnser.NewAliasRecord("www.example.org.", "example.org."),
+		// an alias across zones
+		dnser.NewAliasRecord("www.example.com.", "www.example.org."),
+	}
+	actions := [][]dnser.Action{
+		{{Type: dnser.Upsert, Record: records[0]}},
+		{{Type: dnser.Upsert, Record: records[1]}},
+		{{Type: dnser.Upsert, Record: records[2]}},
+	}
+	if err := a.Process(context.Background(), actions); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortRecords(got), sortRecords(withDefaultTTL(records)); !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
+
+func TestRoute53_ProcessSplitsBatches(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.AddZone("example.org", false)
+
+	actions := make([]dnser.Action, 600)
+	for i := range actions {
+		actions[i] = dnser.Action{
+			Type:   dnser.Upsert,
+			Record: dnser.NewRecord(fmt.Sprintf("host-%d.example.org.", i), "10.0.0.1"),
+		}
+	}
+	if err := a.Process(context.Background(), [][]dnser.Action{actions}); err != nil {
+		t.Fatal(err)
+	}
+
+	// an UPSERT counts twice towards the limit of 1000 records per batch
+	if got := len(server.Batches()); got != 2 {
+		t.Errorf("len(Batches()) = %d, want 2", got)
+	}
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(actions) {
+		t.Errorf("len(List()) = %d, want %d", len(got), len(actions))
+	}
+}
+
+func TestRoute53_ProcessInvalidChangeBatch(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.AddZone("example.org", false)
+
+	err := a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewAliasRecord("www.example.org.", "missing.example.org."),
+	}}})
+
+	var invalid *types.InvalidChangeBatch
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Process() error = %v, want InvalidChangeBatch", err)
+	}
+	if len(server.Batches()) != 0 {
+		t.Errorf("Batches() = %v, want none", server.Batches())
+	}
+}
+
+func TestRoute53_ProcessRollback(t *testing.T) {
+	server, a := newFakeRoute53(t, WithRollback())
+	zoneID := server.AddZone("example.org", false)
+	server.AddRecordSets(zoneID, route53fake.ResourceRecordSet{
+		Name: "example.org.", Type: "A", TTL: int64Ptr(300),
+		ResourceRecords: []route53fake.ResourceRecord{{Value: "127.0.0.1"}},
+	})
+	before := server.RecordSets(zoneID)
+
+	err := a.Process(context.Background(), [][]dnser.Action{
+		{
+			{Type: dnser.Upsert, Record: dnser.NewRecord("example.org.", "127.0.0.2")},
+			{Type: dnser.Upsert, Record: dnser.NewAliasRecord("www.example.org.", "example.org.")},
+		},
+		{{Type: dnser.Delete, Record: dnser.NewAliasRecord("old.example.org.", "example.org.")}},
+	})
+
+	var rollback *RollbackError
+	if !errors.As(err, &rollback) {
+		t.Fatalf("Process() error = %v, want RollbackError", err)
+	}
+	if rollback.RollbackErr != nil || len(rollback.Reverted) != 2 {
+		t.Errorf("Process() rolled back %v with error %v, want both upserts", rollback.Reverted, rollback.RollbackErr)
+	}
+	if after := server.RecordSets(zoneID); !reflect.DeepEqual(after, before) {
+		t.Errorf("RecordSets() = %v, want %v", after, before)
+	}
+}
+
+func TestRoute53_ProcessRollbackCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, a := newFakeRoute53(t, WithRollback(), WithWait(WaitOptions{
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		// cancel while waiting for the first change
+		OnProgress: func(ChangeProgress) { cancel() },
+	}))
+	server.PendingPolls = 1
+	zoneID := server.AddZone("example.org", false)
+	before := server.RecordSets(zoneID)
+
+	err := a.Process(ctx, [][]dnser.Action{
+		{{Type: dnser.Upsert, Record: dnser.NewRecord("example.org.", "127.0.0.1")}},
+		{{Type: dnser.Upsert, Record: dnser.NewAliasRecord("www.example.org.", "example.org.")}},
+	})
+
+	var rollback *RollbackError
+	if !errors.As(err, &rollback) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Process() error = %v, want a RollbackError of context.Canceled", err)
+	}
+	if rollback.RollbackErr != nil || len(rollback.Reverted) != 1 {
+		t.Errorf("Process() rolled back %v with error %v, want the first upsert", rollback.Reverted, rollback.RollbackErr)
+	}
+	if after := server.RecordSets(zoneID); !reflect.DeepEqual(after, before) {
+		t.Errorf("RecordSets() = %v, want %v", after, before)
+	}
+}
+
+func TestRoute53_ProcessWaitsForChanges(t *testing.T) {
+	progress := make([]types.ChangeStatus, 0)
+	server, a := newFakeRoute53(t, WithWait(WaitOptions{
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		OnProgress: func(p ChangeProgress) {
+			progress = append(progress, p.Status)
+		},
+	}))
+	server.PendingPolls = 2
+	server.AddZone("example.org", false)
+
+	err := a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("example.org.", "127.0.0.1"),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.ChangeStatus{types.ChangeStatusPending, types.ChangeStatusPending, types.ChangeStatusInsync}
+	if !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+}
+
+func TestRoute53_EnsureZones(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	parentID := server.AddZone("example.org", false)
+
+	created, err := a.EnsureZones(context.Background(), []config.Zone{
+		{Name: "example.org."},
+		{Name: "dev.example.org.", Comment: "development"},
+		{Name: "internal.example.org.", Private: true, VPCID: "vpc-1", VPCRegion: "eu-west-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []config.Domain{"dev.example.org.", "internal.example.org."}; !reflect.DeepEqual(created, want) {
+		t.Errorf("EnsureZones() = %v, want %v", created, want)
+	}
+
+	delegations := make([]string, 0)
+	for _, rrset := range server.RecordSets(parentID) {
+		if rrset.Type == "NS" {
+			delegations = append(delegations, rrset.Name)
+		}
+	}
+	if want := []string{"example.org.", "dev.example.org."}; !reflect.DeepEqual(delegations, want) {
+		t.Errorf("NS records of the parent zone = %v, want %v", delegations, want)
+	}
+
+	// records of the new zone can be processed right away
+	err = a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("app.dev.example.org.", "10.0.0.1"),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	devID := server.ZoneID("dev.example.org")
+	if got := len(server.RecordSets(devID)); got != 3 {
+		t.Errorf("len(RecordSets(dev.example.org.)) = %d, want NS, SOA and A", got)
+	}
+}
+
+func TestRoute53_EnsureZonesFiltered(t *testing.T) {
+	server, a := newFakeRoute53(t, WithZoneFilter(ZoneFilter{ExcludeNames: []config.Domain{"dev.example.org."}}))
+	server.AddZone("example.org", false)
+	server.AddZone("dev.example.org", false)
+
+	created, err := a.EnsureZones(context.Background(), []config.Zone{
+		{Name: "example.org."},
+		{Name: "dev.example.org."},
+	})
+	if err == nil {
+		t.Error("EnsureZones() error = nil, want an error for the excluded zone")
+	}
+	if len(created) > 0 {
+		t.Errorf("EnsureZones() = %v, want no created zones", created)
+	}
+}
+
+func TestRoute53_EnsureZonesTagged(t *testing.T) {
+	filter := WithZoneFilter(ZoneFilter{Tags: map[string]string{"owner": "dnser"}})
+	server, a := newFakeRoute53(t, filter)
+	parentID := server.AddZone("example.org", false)
+	server.SetTags(parentID, map[string]string{"owner": "dnser"})
+	zones := []config.Zone{{Name: "example.org."}, {Name: "dev.example.org."}}
+
+	created, err := a.EnsureZones(context.Background(), zones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []config.Domain{"dev.example.org."}; !reflect.DeepEqual(created, want) {
+		t.Errorf("EnsureZones() = %v, want %v", created, want)
+	}
+	if got := server.Tags(server.ZoneID("dev.example.org")); got["owner"] != "dnser" {
+		t.Errorf("tags of the created zone = %v, want the tags of the ZoneFilter", got)
+	}
+
+	// the next run, e.g. of the next commit, manages the created zone like the others
+	_, a = newFakeRoute53WithServer(t, server, filter)
+	created, err = a.EnsureZones(context.Background(), zones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) > 0 {
+		t.Errorf("EnsureZones() = %v, want no created zones", created)
+	}
+}
+
+func TestRoute53_EnsureZonesNotCreatable(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ZoneFilter
+		zone   config.Zone
+	}{{
+		name:   "zone IDs",
+		filter: ZoneFilter{IncludeIDs: []string{"Z000001"}},
+		zone:   config.Zone{Name: "example.org."},
+	}, {
+		name:   "zone names",
+		filter: ZoneFilter{IncludeNames: []config.Domain{"example.org."}},
+		zone:   config.Zone{Name: "dev.example.org."},
+	}, {
+		name:   "visibility",
+		filter: ZoneFilter{Visibility: PrivateZones},
+		zone:   config.Zone{Name: "example.org."},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, a := newFakeRoute53(t, WithZoneFilter(tt.filter))
+			if _, err := a.EnsureZones(context.Background(), []config.Zone{tt.zone}); err == nil {
+				t.Error("EnsureZones() error = nil, want an error for a zone the ZoneFilter excludes")
+			}
+			if id := server.ZoneID(string(tt.zone.Name)); id != "" {
+				t.Errorf("EnsureZones() created %s", id)
+			}
+		})
+	}
+}
+
+func TestRoute53_SplitHorizon(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.AddZone("example.org", false)
+	server.AddZone("example.org", true)
+	server.AddZone("example.net", false)
+
+	if _, err := a.List(context.Background()); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	err := a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("example.net.", "10.0.0.1"),
+	}}})
+	if err != nil {
+		t.Errorf("Process() error = %v, want only the records of example.org. to be ambiguous", err)
+	}
+	err = a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("www.example.org.", "10.0.0.1"),
+	}}})
+	if err == nil {
+		t.Error("Process() error = nil, want an error for the ambiguous zone")
+	}
+}
+
+func TestRoute53_ProcessCNAMEs(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.AddZone("example.org", false)
+	server.AddZone("example.net", false)
+
+	m := massager.Massager{
+		Desired: []config.Item{{
+			IP:     "127.0.0.1",
+			Domain: "example.org.",
+			Aliases: []config.Node{
+				{Value: "www.example.org.", Children: []config.Node{{Value: "api.example.org."}}},
+				{Value: "example.net."},
+			},
+		}},
+		Strategy: dnser.CNAMERecords,
+	}
+	if err := a.Process(context.Background(), m.Plan().Actions); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dnser.DNSRecord{
+		dnser.NewCNAMERecord("api.example.org.", "www.example.org."),
+		dnser.NewRecord("example.net.", "127.0.0.1"),
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		dnser.NewCNAMERecord("www.example.org.", "example.org."),
+	}
+	if got = sortRecords(got); !reflect.DeepEqual(got, withDefaultTTL(want)) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	m.Current = got
+	if actions := m.Plan().Actions; len(actions) != 0 {
+		t.Errorf("Plan() after Process = %v, want no actions", actions)
+	}
+}
+
+func TestRoute53_ProcessRecordSets(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	zoneID := server.AddZone("example.org", false)
+
+	m := massager.Massager{
+		Records: []config.Record{{
+			Name:   "example.org.",
+			Type:   config.TXT,
+			TTL:    3600,
+			Values: []string{"v=spf1 -all", `say "hi"`},
+		}, {
+			Name:   "example.org.",
+			Type:   config.MX,
+			TTL:    300,
+			Values: []string{"10 mail.example.org.", "20 backup.example.org."},
+		}, {
+			Name:   "_http._tcp.example.org.",
+			Type:   config.SRV,
+			TTL:    300,
+			Values: []string{"10 5 8080 api.example.org."},
+		}},
+	}
+	if err := a.Process(context.Background(), m.Plan().Actions); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := server.Batches()
+	if len(batches) != 1 || len(batches[0].Changes) != 3 {
+		t.Fatalf("Batches() = %v, want a single batch that upserts the three record sets", batches)
+	}
+	for _, rrset := range server.RecordSets(zoneID) {
+		if rrset.Type == "TXT" && (len(rrset.ResourceRecords) != 2 || rrset.ResourceRecords[1].Value != `"say \"hi\""`) {
+			t.Errorf("TXT record set = %v, want two quoted values", rrset.ResourceRecords)
+		}
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Current = got
+	if actions := m.Plan().Actions; len(actions) != 0 {
+		t.Errorf("Plan() after Process = %v, want no actions", actions)
+	}
+}
+
+func TestRoute53_ProcessWildcard(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.AddZone("example.org", false)
+
+	m := massager.Massager{
+		Desired: []config.Item{{
+			IP:      "10.0.0.1",
+			Domain:  "preview.example.org.",
+			Aliases: []config.Node{{Value: "*.preview.example.org."}},
+		}},
+	}
+	if err := a.Process(context.Background(), m.Plan().Actions); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dnser.DNSRecord{
+		dnser.NewAliasRecord("*.preview.example.org.", "preview.example.org."),
+		dnser.NewRecord("preview.example.org.", "10.0.0.1"),
+	}
+	if got = sortRecords(got); !reflect.DeepEqual(got, withDefaultTTL(want)) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	m.Current = got
+	if actions := m.Plan().Actions; len(actions) != 0 {
+		t.Errorf("Plan() after Process = %v, want no actions", actions)
+	}
+}
+
+func TestRoute53_ProcessPTR(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	server.AddZone("example.org", false)
+	server.AddZone("0.0.10.in-addr.arpa", false)
+
+	m := massager.Massager{
+		Desired:      []config.Item{{IP: "10.0.0.1", Domain: "example.org."}},
+		ReverseZones: []config.Domain{"0.0.10.in-addr.arpa."},
+	}
+	if err := a.Process(context.Background(), m.Plan().Actions); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dnser.DNSRecord{
+		{Type: dnser.TypePTR, Name: "1.0.0.10.in-addr.arpa.", Target: "example.org."},
+		dnser.NewRecord("example.org.", "10.0.0.1"),
+	}
+	if got = sortRecords(got); !reflect.DeepEqual(got, withDefaultTTL(want)) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	m.Current = got
+	if actions := m.Plan().Actions; len(actions) != 0 {
+		t.Errorf("Plan() after Process = %v, want no actions", actions)
+	}
+}
+
+func TestRoute53_NormalizeAliasTargets(t *testing.T) {
+	server, a := newFakeRoute53(t)
+	zoneID := server.AddZone("example.org", false)
+	server.AddZone("example.net", false)
+
+	err := a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("origin.example.org.", "10.0.0.1"),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{Config: []config.Item{{
+		Domain: "app.example.net.",
+		Alias: &config.AliasTarget{
+			Type:                 config.HostedZone,
+			DNSName:              "origin.example.org.",
+			HostedZoneID:         zoneID,
+			EvaluateTargetHealth: true,
+		},
+	}}}
+	cfg, err = a.NormalizeAliasTargets(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NormalizeAliasTargets() error = %v", err)
+	}
+	if id := cfg.Config[0].Alias.HostedZoneID; id != "" {
+		t.Errorf("NormalizeAliasTargets() hosted zone ID = %q, want it omitted", id)
+	}
+
+	m := massager.Massager{Desired: cfg.Config}
+	if err := a.Process(context.Background(), m.Plan().Actions); err != nil {
+		t.Fatal(err)
+	}
+	m.Current, err = a.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions := m.Plan().Actions; len(actions) > 0 {
+		t.Errorf("Plan() after Process = %v, want no actions", actions)
+	}
+}
@@ -0,0 +1,169 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Route53 is a global service, its API is signed for us-east-1.
+const defaultRoute53Region = "us-east-1"
+
+// Route53Option configures a Route53 instance constructed by NewRoute53WithOptions.
+type Route53Option func(*route53Options)
+
+type route53Options struct {
+	region   string
+	profile  string
+	endpoint string
+
+	staticID     string
+	staticSecret string
+
+	webIdentityRoleARN   string
+	webIdentityTokenFile string
+
+	assumeRoleARN     string
+	assumeExternalID  string
+	assumeSessionName string
+
+	wait    WaitOptions
+	retry   RetryOptions
+	limiter *RateLimiter
+
+	zoneFilter       ZoneFilter
+	rollback         bool
+	healthCheckOwner string
+}
+
+// WithRegion sets the AWS region. Defaults to the region of the
+// default credential chain, or us-east-1 if none is configured.
+func WithRegion(region string) Route53Option {
+	return func(o *route53Options) {
+		o.region = region
+	}
+}
+
+// WithProfile uses the named profile from the shared AWS config files.
+func WithProfile(profile string) Route53Option {
+	return func(o *route53Options) {
+		o.profile = profile
+	}
+}
+
+// WithStaticCredentials uses an AWS access key and secret
+// instead of the default credential chain.
+func WithStaticCredentials(id, secret string) Route53Option {
+	return func(o *route53Options) {
+		o.staticID = id
+		o.staticSecret = secret
+	}
+}
+
+// WithWebIdentity assumes roleARN with the OIDC token stored in tokenFile,
+// e.g. the token a CI system issues for the job.
+func WithWebIdentity(roleARN, tokenFile string) Route53Option {
+	return func(o *route53Options) {
+		o.webIdentityRoleARN = roleARN
+		o.webIdentityTokenFile = tokenFile
+	}
+}
+
+// WithAssumeRole assumes roleARN with STS on top of the other credentials,
+// e.g. to manage zones that live in another account.
+// externalID and sessionName are optional.
+func WithAssumeRole(roleARN, externalID, sessionName string) Route53Option {
+	return func(o *route53Options) {
+		o.assumeRoleARN = roleARN
+		o.assumeExternalID = externalID
+		o.assumeSessionName = sessionName
+	}
+}
+
+// WithEndpoint sends the Route53 API requests to a custom endpoint URL.
+func WithEndpoint(url string) Route53Option {
+	return func(o *route53Options) {
+		o.endpoint = url
+	}
+}
+
+// NewRoute53WithOptions constructs a Route53 instance.
+// Without options the default AWS credential chain is used.
+func NewRoute53WithOptions(ctx context.Context, opts ...Route53Option) (Route53, error) {
+	o := route53Options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if strings.Contains(o.healthCheckOwner, "/") {
+		return Route53{}, fmt.Errorf("health check owner %q must not contain a slash", o.healthCheckOwner)
+	}
+
+	cfg, err := o.awsConfig(ctx)
+	if err != nil {
+		return Route53{}, err
+	}
+
+	clientOpts := []func(*route53.Options){withoutClientRetries}
+	if o.endpoint != "" {
+		clientOpts = append(clientOpts, route53.WithEndpointResolver(route53.EndpointResolverFromURL(o.endpoint)))
+	}
+	return newRoute53(route53.NewFromConfig(cfg, clientOpts...), o), nil
+}
+
+func (o route53Options) awsConfig(ctx context.Context) (aws.Config, error) {
+	if o.staticID != "" && o.webIdentityRoleARN != "" {
+		return aws.Config{}, errors.New("static credentials and web identity are mutually exclusive")
+	}
+
+	loadOpts := []func(*awsConfig.LoadOptions) error{
+		awsConfig.WithDefaultRegion(defaultRoute53Region),
+	}
+	if o.region != "" {
+		loadOpts = append(loadOpts, awsConfig.WithRegion(o.region))
+	}
+	if o.profile != "" {
+		loadOpts = append(loadOpts, awsConfig.WithSharedConfigProfile(o.profile))
+	}
+	if o.staticID != "" {
+		loadOpts = append(loadOpts, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(o.staticID, o.staticSecret, ""),
+		))
+	}
+
+	cfg, err := awsConfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if o.webIdentityRoleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+			sts.NewFromConfig(cfg),
+			o.webIdentityRoleARN,
+			stscreds.IdentityTokenFile(o.webIdentityTokenFile),
+		))
+	}
+	if o.assumeRoleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+			sts.NewFromConfig(cfg),
+			o.assumeRoleARN,
+			func(ro *stscreds.AssumeRoleOptions) {
+				if o.assumeExternalID != "" {
+					ro.ExternalID = aws.String(o.assumeExternalID)
+				}
+				if o.assumeSessionName != "" {
+					ro.RoleSessionName = o.assumeSessionName
+				}
+			},
+		))
+	}
+	return cfg, nil
+}
@@ -0,0 +1,79 @@
+package adapter
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/flood4life/dnser"
+)
+
+// txtChunkLength is the longest character string of a TXT record.
+const txtChunkLength = 255
+
+func resourceRecord(record dnser.DNSRecord) types.ResourceRecord {
+	value := string(record.Target)
+	if record.Type == dnser.TypeTXT {
+		value = quoteTXT(value)
+	}
+	return types.ResourceRecord{Value: &value}
+}
+
+func recordTTL(record dnser.DNSRecord) int64 {
+	if record.TTL > 0 {
+		return record.TTL
+	}
+	return defaultTTL
+}
+
+// quoteTXT converts the text of a TXT record into quoted character strings
+// of at most txtChunkLength characters each.
+func quoteTXT(text string) string {
+	chunks := make([]string, 0, len(text)/txtChunkLength+1)
+	for {
+		chunk := text
+		if len(chunk) > txtChunkLength {
+			chunk = chunk[:txtChunkLength]
+		}
+		text = text[len(chunk):]
+
+		escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(chunk)
+		chunks = append(chunks, `"`+escaped+`"`)
+		if text == "" {
+			return strings.Join(chunks, " ")
+		}
+	}
+}
+
+// unquoteTXT joins the quoted character strings of a TXT record back into its text.
+func unquoteTXT(value string) string {
+	if !strings.HasPrefix(value, `"`) {
+		return value
+	}
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+3 < len(value) && isOctal(value[i+1:i+4]):
+			b.WriteByte((value[i+1]-'0')<<6 | (value[i+2]-'0')<<3 | (value[i+3] - '0'))
+			i += 3
+		case c == '\\' && i+1 < len(value):
+			b.WriteByte(value[i+1])
+			i++
+		case quoted:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isOctal(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '7' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package adapter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuoteTXT(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "v=spf1 -all", want: `"v=spf1 -all"`},
+		{name: "empty", text: "", want: `""`},
+		{name: "quotes and backslashes", text: `say "hi" \o/`, want: `"say \"hi\" \\o/"`},
+		{name: "longer than a character string", text: long, want: `"` + long[:255] + `" "` + long[255:] + `"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteTXT(tt.text); got != tt.want {
+				t.Errorf("quoteTXT() = %q, want %q", got, tt.want)
+			}
+			if got := unquoteTXT(tt.want); got != tt.text {
+				t.Errorf("unquoteTXT() = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestUnquoteTXT_octal(t *testing.T) {
+	if got, want := unquoteTXT(`"caf\303\251"`), "café"; got != want {
+		t.Errorf("unquoteTXT() = %q, want %q", got, want)
+	}
+}
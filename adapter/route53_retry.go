@@ -0,0 +1,317 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	defaultRetryMaxAttempts = 8
+	defaultRetryBaseDelay   = 200 * time.Millisecond
+	defaultRetryMaxDelay    = 20 * time.Second
+
+	// Route53 allows 5 API requests per second per account.
+	defaultRequestsPerSecond = 5
+)
+
+// retryableErrorCodes are the Route53 error codes that are worth retrying.
+var retryableErrorCodes = map[string]bool{
+	"Throttling":              true,
+	"ThrottlingException":     true,
+	"PriorRequestNotComplete": true,
+	"RequestLimitExceeded":    true,
+}
+
+// RetryOptions configures retries of Route53 API calls that failed
+// because of throttling or a concurrent change.
+type RetryOptions struct {
+	// MaxAttempts is the number of attempts per call, including the first one. Defaults to 8.
+	MaxAttempts int
+	// BaseDelay is the upper bound of the jittered delay before the first retry,
+	// the bound doubles with every retry. Defaults to 200 milliseconds.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. Defaults to 20 seconds.
+	MaxDelay time.Duration
+}
+
+// WithRetry configures retries of throttled API calls.
+func WithRetry(r RetryOptions) Route53Option {
+	return func(o *route53Options) {
+		o.retry = r
+	}
+}
+
+// WithRateLimiter makes the adapter share l with other adapters,
+// e.g. all adapters that use the same AWS account.
+// By default every adapter has its own limiter of 5 requests per second.
+func WithRateLimiter(l *RateLimiter) Route53Option {
+	return func(o *route53Options) {
+		o.limiter = l
+	}
+}
+
+// RateLimiter spaces out requests to stay under a request rate.
+// It's safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter constructs a RateLimiter that allows requestsPerSecond requests per second.
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+	}
+}
+
+// Wait blocks until the next request is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, time.Until(at))
+}
+
+// throttledClient wraps the Route53 API calls used by the adapter
+// with rate limiting and retries. It's the only one retrying the calls:
+// the client must not retry on its own, see withoutClientRetries.
+type throttledClient struct {
+	client  *route53.Client
+	retry   RetryOptions
+	limiter *RateLimiter
+	jitter  *jitter
+}
+
+// withoutClientRetries disables the retries of the SDK, so that every attempt
+// goes through the rate limiter and the attempts of both don't multiply.
+func withoutClientRetries(o *route53.Options) {
+	o.Retryer = aws.NopRetryer{}
+}
+
+// jitter is a source of random delays that is safe for concurrent use.
+// Every client has its own, seeded one, so that processes don't retry in lockstep.
+type jitter struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newJitter() *jitter {
+	return &jitter{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// int63n returns a random number in [0, n).
+func (j *jitter) int63n(n int64) int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.rnd.Int63n(n)
+}
+
+func newThrottledClient(client *route53.Client, o route53Options) throttledClient {
+	limiter := o.limiter
+	if limiter == nil {
+		limiter = NewRateLimiter(defaultRequestsPerSecond)
+	}
+	return throttledClient{
+		client:  client,
+		retry:   o.retry,
+		limiter: limiter,
+		jitter:  newJitter(),
+	}
+}
+
+var (
+	_ route53.ListHostedZonesAPIClient = throttledClient{}
+	_ ListResourceRecordSetsAPIClient  = throttledClient{}
+	_ GetChangeAPIClient               = throttledClient{}
+)
+
+func (c throttledClient) ListHostedZones(ctx context.Context, input *route53.ListHostedZonesInput, optFns ...func(*route53.Options)) (output *route53.ListHostedZonesOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.ListHostedZones(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) ListHostedZonesByVPC(ctx context.Context, input *route53.ListHostedZonesByVPCInput, optFns ...func(*route53.Options)) (output *route53.ListHostedZonesByVPCOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.ListHostedZonesByVPC(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) ListTagsForResources(ctx context.Context, input *route53.ListTagsForResourcesInput, optFns ...func(*route53.Options)) (output *route53.ListTagsForResourcesOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.ListTagsForResources(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) ListResourceRecordSets(ctx context.Context, input *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (output *route53.ListResourceRecordSetsOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.ListResourceRecordSets(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) ChangeResourceRecordSets(ctx context.Context, input *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (output *route53.ChangeResourceRecordSetsOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.ChangeResourceRecordSets(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) GetChange(ctx context.Context, input *route53.GetChangeInput, optFns ...func(*route53.Options)) (output *route53.GetChangeOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.GetChange(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) ListHealthChecks(ctx context.Context, input *route53.ListHealthChecksInput, optFns ...func(*route53.Options)) (output *route53.ListHealthChecksOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.ListHealthChecks(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) CreateHealthCheck(ctx context.Context, input *route53.CreateHealthCheckInput, optFns ...func(*route53.Options)) (output *route53.CreateHealthCheckOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.CreateHealthCheck(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) UpdateHealthCheck(ctx context.Context, input *route53.UpdateHealthCheckInput, optFns ...func(*route53.Options)) (output *route53.UpdateHealthCheckOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.UpdateHealthCheck(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) DeleteHealthCheck(ctx context.Context, input *route53.DeleteHealthCheckInput, optFns ...func(*route53.Options)) (output *route53.DeleteHealthCheckOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.DeleteHealthCheck(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) ChangeTagsForResource(ctx context.Context, input *route53.ChangeTagsForResourceInput, optFns ...func(*route53.Options)) (output *route53.ChangeTagsForResourceOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.ChangeTagsForResource(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) CreateHostedZone(ctx context.Context, input *route53.CreateHostedZoneInput, optFns ...func(*route53.Options)) (output *route53.CreateHostedZoneOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.CreateHostedZone(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c throttledClient) GetHostedZone(ctx context.Context, input *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (output *route53.GetHostedZoneOutput, err error) {
+	err = c.do(ctx, func() error {
+		output, err = c.client.GetHostedZone(ctx, input, optFns...)
+		return err
+	})
+	return output, err
+}
+
+// do calls fn until it succeeds, fails with an error that is not retryable,
+// or runs out of attempts.
+func (c throttledClient) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < c.retry.maxAttempts(); attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.retry.backoff(attempt, c.jitter)); err != nil {
+				return err
+			}
+		}
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err = fn()
+		if !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func isRetryable(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && retryableErrorCodes[apiErr.ErrorCode()]
+}
+
+// backoff returns a random delay between 0 and the exponentially growing bound ("full jitter").
+func (r RetryOptions) backoff(attempt int, j *jitter) time.Duration {
+	bound := r.maxDelay()
+	if attempt < 32 {
+		if d := r.baseDelay() << uint(attempt-1); d > 0 && d < bound {
+			bound = d
+		}
+	}
+	return time.Duration(j.int63n(int64(bound) + 1))
+}
+
+func (r RetryOptions) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return defaultRetryMaxAttempts
+}
+
+func (r RetryOptions) baseDelay() time.Duration {
+	if r.BaseDelay > 0 {
+		return r.BaseDelay
+	}
+	return defaultRetryBaseDelay
+}
+
+func (r RetryOptions) maxDelay() time.Duration {
+	if r.MaxDelay > 0 {
+		return r.MaxDelay
+	}
+	return defaultRetryMaxDelay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
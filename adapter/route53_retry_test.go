@@ -0,0 +1,102 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestThrottledClient_do(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "Throttling"}
+	notComplete := &smithy.GenericAPIError{Code: "PriorRequestNotComplete"}
+	invalid := &smithy.GenericAPIError{Code: "InvalidChangeBatch"}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{{
+		name:      "succeeds after throttling",
+		errs:      []error{throttled, notComplete, nil},
+		wantCalls: 3,
+		wantErr:   nil,
+	}, {
+		name:      "does not retry other errors",
+		errs:      []error{invalid, nil},
+		wantCalls: 1,
+		wantErr:   invalid,
+	}, {
+		name:      "gives up after max attempts",
+		errs:      []error{throttled, throttled, throttled, throttled},
+		wantCalls: 3,
+		wantErr:   throttled,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := throttledClient{
+				retry:   RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+				limiter: NewRateLimiter(1000),
+				jitter:  newJitter(),
+			}
+			calls := 0
+			err := c.do(context.Background(), func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("do() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("do() called fn %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	l := NewRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 requests at 100 rps took %v, want at least 40ms", elapsed)
+	}
+}
+
+func TestRoute53_RetriesOnlyOnce(t *testing.T) {
+	server, a := newFakeRoute53(t, WithRetry(RetryOptions{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	server.AddZone("example.org", false)
+	server.ThrottleNext(3)
+
+	// the SDK doesn't retry on its own, so two attempts make two requests
+	if _, err := a.List(context.Background()); err == nil {
+		t.Error("List() error = nil, want Throttling")
+	}
+	if got := server.Requests(); got != 2 {
+		t.Errorf("List() made %d requests, want 2", got)
+	}
+}
+
+func TestRetryOptions_backoff(t *testing.T) {
+	r := RetryOptions{BaseDelay: time.Second, MaxDelay: time.Hour}
+	a, b := newJitter(), newJitter()
+	same := true
+	for attempt := 1; attempt < 10; attempt++ {
+		if a.int63n(1<<62) != b.int63n(1<<62) {
+			same = false
+		}
+		if d := r.backoff(attempt, a); d < 0 || d > time.Second<<uint(attempt-1) {
+			t.Errorf("backoff(%d) = %v, want at most %v", attempt, d, time.Second<<uint(attempt-1))
+		}
+	}
+	if same {
+		t.Error("two jitters produced the same sequence")
+	}
+}
@@ -0,0 +1,130 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flood4life/dnser"
+)
+
+// rollbackTimeout bounds the rollback, which doesn't use the context of Process.
+const rollbackTimeout = 5 * time.Minute
+
+// WithRollback makes Process transactional. The records are listed before
+// the changes are applied, and if a change fails, the changes applied before it
+// are reverted newest first. Process then returns a *RollbackError.
+// The rollback also runs when the context of Process is cancelled or times out.
+func WithRollback() Route53Option {
+	return func(o *route53Options) {
+		o.rollback = true
+	}
+}
+
+// RollbackError is returned by Process when a change failed and the applied changes were rolled back.
+type RollbackError struct {
+	// Err is the error of the failed change.
+	Err error
+	// Reverted are the applied actions that were rolled back.
+	Reverted []dnser.Action
+	// Remaining are the applied actions that are still in effect, because the rollback failed.
+	Remaining []dnser.Action
+	// RollbackErr is the error that stopped the rollback.
+	RollbackErr error
+}
+
+// Error implements error.
+func (e *RollbackError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%v; rolled back %d actions, rollback failed with %v leaving %d actions applied",
+			e.Err, len(e.Reverted), e.RollbackErr, len(e.Remaining))
+	}
+	return fmt.Sprintf("%v; rolled back %d actions", e.Err, len(e.Reverted))
+}
+
+// Unwrap returns the error of the failed change.
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+func (a Route53) processWithRollback(ctx context.Context, actionGroups [][]dnser.Action) error {
+	snapshot, err := a.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied, err := a.processGroups(ctx, actionGroups)
+	if err == nil {
+		return nil
+	}
+	// ctx is likely the reason of the failure, e.g. a deadline, so it can't be used to roll back
+	rollbackCtx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+	return a.rollBack(rollbackCtx, err, applied, snapshot)
+}
+
+// rollBack reverts the applied change sets, newest first,
+// to the records of the snapshot.
+func (a Route53) rollBack(ctx context.Context, cause error, applied []changeSet, snapshot []dnser.DNSRecord) error {
+	result := &RollbackError{
+		Err:      cause,
+		Reverted: make([]dnser.Action, 0),
+	}
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := a.revertChangeSet(ctx, applied[i], snapshot); err != nil {
+			result.RollbackErr = err
+			for _, set := range applied[:i+1] {
+				result.Remaining = append(result.Remaining, set.actions...)
+			}
+			return result
+		}
+		result.Reverted = append(result.Reverted, applied[i].actions...)
+	}
+	return result
+}
+
+func (a Route53) revertChangeSet(ctx context.Context, set changeSet, snapshot []dnser.DNSRecord) error {
+	sets, err := a.changeSets(inverseActions(set.actions, snapshot))
+	if err != nil {
+		return err
+	}
+	// the inverse of a change set may need more than one batch
+	for _, inverse := range sets {
+		changeID, err := a.submitChangeSet(ctx, inverse)
+		if err != nil {
+			return err
+		}
+		if err := a.wait.wait(ctx, a.client, inverse.zoneID, changeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inverseActions returns the actions that restore the records of the snapshot
+// changed by actions: deleted records are recreated, upserted records
+// are either restored to their previous version or deleted.
+// A record set with several values is restored as a whole.
+func inverseActions(actions []dnser.Action, snapshot []dnser.DNSRecord) []dnser.Action {
+	before := make(map[dnser.RecordKey][]dnser.DNSRecord, len(snapshot))
+	for _, r := range snapshot {
+		before[r.Key()] = append(before[r.Key()], r)
+	}
+
+	result := make([]dnser.Action, 0, len(actions))
+	restored := make(map[dnser.RecordKey]bool)
+	for _, action := range actions {
+		key := action.Record.Key()
+		old, existed := before[key]
+		switch {
+		case existed && !restored[key]:
+			restored[key] = true
+			for _, r := range old {
+				result = append(result, dnser.Action{Type: dnser.Upsert, Record: r})
+			}
+		case !existed && action.Type == dnser.Upsert:
+			result = append(result, dnser.Action{Type: dnser.Delete, Record: action.Record})
+		}
+	}
+	return result
+}
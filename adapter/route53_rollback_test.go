@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/flood4life/dnser"
+)
+
+func TestInverseActions(t *testing.T) {
+	changed := dnser.NewRecord("example.org.", "127.0.0.1")
+	deleted := dnser.NewAliasRecord("www.example.org.", "example.org.")
+	snapshot := []dnser.DNSRecord{changed, deleted}
+
+	created := dnser.NewAliasRecord("api.example.org.", "example.org.")
+	actions := []dnser.Action{
+		{Type: dnser.Upsert, Record: dnser.NewRecord("example.org.", "127.0.0.2")},
+		{Type: dnser.Delete, Record: deleted},
+		{Type: dnser.Upsert, Record: created},
+	}
+	want := []dnser.Action{
+		{Type: dnser.Upsert, Record: changed},
+		{Type: dnser.Upsert, Record: deleted},
+		{Type: dnser.Delete, Record: created},
+	}
+
+	if got := inverseActions(actions, snapshot); !reflect.DeepEqual(got, want) {
+		t.Errorf("inverseActions() = %v, want %v", got, want)
+	}
+}
+
+func TestInverseActions_recordSet(t *testing.T) {
+	spf := dnser.DNSRecord{Type: dnser.TypeTXT, Name: "example.org.", Target: "v=spf1 -all", TTL: 300}
+	verification := dnser.DNSRecord{Type: dnser.TypeTXT, Name: "example.org.", Target: "verification=abc", TTL: 300}
+	snapshot := []dnser.DNSRecord{spf, verification}
+
+	actions := []dnser.Action{
+		{Type: dnser.Upsert, Record: spf},
+		{Type: dnser.Upsert, Record: dnser.DNSRecord{Type: dnser.TypeTXT, Name: "example.org.", Target: "verification=def", TTL: 300}},
+	}
+	want := []dnser.Action{
+		{Type: dnser.Upsert, Record: spf},
+		{Type: dnser.Upsert, Record: verification},
+	}
+
+	if got := inverseActions(actions, snapshot); !reflect.DeepEqual(got, want) {
+		t.Errorf("inverseActions() = %v, want %v", got, want)
+	}
+}
+
+func TestRollbackError(t *testing.T) {
+	cause := errors.New("InvalidChangeBatch")
+	var err error = &RollbackError{Err: cause, Reverted: make([]dnser.Action, 2)}
+	if !errors.Is(err, cause) {
+		t.Errorf("errors.Is() = false, want the RollbackError to wrap %v", cause)
+	}
+	if got, want := err.Error(), "InvalidChangeBatch; rolled back 2 actions"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
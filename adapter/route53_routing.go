@@ -0,0 +1,67 @@
+package adapter
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/flood4life/dnser/config"
+)
+
+// applyRouting sets the routing policy fields of the record set.
+func applyRouting(recordSet *types.ResourceRecordSet, routing config.Routing) {
+	if routing.HealthCheckID != "" {
+		recordSet.HealthCheckId = aws.String(routing.HealthCheckID)
+	}
+	if routing.Policy == config.SimpleRouting {
+		return
+	}
+
+	recordSet.SetIdentifier = aws.String(routing.SetIdentifier)
+	switch routing.Policy {
+	case config.WeightedRouting:
+		recordSet.Weight = aws.Int64(routing.Weight)
+	case config.LatencyRouting:
+		recordSet.Region = types.ResourceRecordSetRegion(routing.Region)
+	case config.FailoverRouting:
+		recordSet.Failover = types.ResourceRecordSetFailover(routing.Failover)
+	case config.GeolocationRouting:
+		recordSet.GeoLocation = &types.GeoLocation{
+			ContinentCode:   optionalString(routing.Geo.ContinentCode),
+			CountryCode:     optionalString(routing.Geo.CountryCode),
+			SubdivisionCode: optionalString(routing.Geo.SubdivisionCode),
+		}
+	}
+}
+
+// routingFromRecordSet reads the routing policy of the record set.
+func routingFromRecordSet(recordSet types.ResourceRecordSet) config.Routing {
+	routing := config.Routing{
+		SetIdentifier: aws.ToString(recordSet.SetIdentifier),
+		HealthCheckID: aws.ToString(recordSet.HealthCheckId),
+	}
+	switch {
+	case recordSet.Weight != nil:
+		routing.Policy = config.WeightedRouting
+		routing.Weight = *recordSet.Weight
+	case recordSet.Region != "":
+		routing.Policy = config.LatencyRouting
+		routing.Region = string(recordSet.Region)
+	case recordSet.Failover != "":
+		routing.Policy = config.FailoverRouting
+		routing.Failover = config.FailoverRole(recordSet.Failover)
+	case recordSet.GeoLocation != nil:
+		routing.Policy = config.GeolocationRouting
+		routing.Geo = config.GeoLocation{
+			ContinentCode:   aws.ToString(recordSet.GeoLocation.ContinentCode),
+			CountryCode:     aws.ToString(recordSet.GeoLocation.CountryCode),
+			SubdivisionCode: aws.ToString(recordSet.GeoLocation.SubdivisionCode),
+		}
+	}
+	return routing
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
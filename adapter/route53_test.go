@@ -0,0 +1,85 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/flood4life/dnser/config"
+)
+
+func TestNewRoute53WithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Route53Option
+		wantErr bool
+	}{{
+		name: "static credentials and custom endpoint",
+		opts: []Route53Option{
+			WithStaticCredentials("id", "secret"),
+			WithEndpoint("http://127.0.0.1:4566"),
+		},
+		wantErr: false,
+	}, {
+		name: "assume role on top of static credentials",
+		opts: []Route53Option{
+			WithStaticCredentials("id", "secret"),
+			WithAssumeRole("arn:aws:iam::123456789012:role/dnser", "external", ""),
+		},
+		wantErr: false,
+	}, {
+		name: "static credentials and web identity",
+		opts: []Route53Option{
+			WithStaticCredentials("id", "secret"),
+			WithWebIdentity("arn:aws:iam::123456789012:role/dnser", "/var/run/token"),
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRoute53WithOptions(context.Background(), tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRoute53WithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouting_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		routing config.Routing
+	}{
+		{name: "simple", routing: config.Routing{}},
+		{name: "simple with health check", routing: config.Routing{HealthCheckID: "hc"}},
+		{name: "weighted", routing: config.Routing{Policy: config.WeightedRouting, SetIdentifier: "blue", Weight: 0}},
+		{name: "latency", routing: config.Routing{Policy: config.LatencyRouting, SetIdentifier: "eu", Region: "eu-west-1"}},
+		{name: "failover", routing: config.Routing{Policy: config.FailoverRouting, SetIdentifier: "main", Failover: config.Primary, HealthCheckID: "hc"}},
+		{name: "geolocation", routing: config.Routing{Policy: config.GeolocationRouting, SetIdentifier: "de", Geo: config.GeoLocation{CountryCode: "DE"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recordSet types.ResourceRecordSet
+			applyRouting(&recordSet, tt.routing)
+			if got := routingFromRecordSet(recordSet); got != tt.routing {
+				t.Errorf("routingFromRecordSet() = %v, want %v", got, tt.routing)
+			}
+		})
+	}
+}
+
+func TestUnescapeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want config.Domain
+	}{
+		{name: "www.example.org.", want: "www.example.org."},
+		{name: `\052.preview.example.org.`, want: "*.preview.example.org."},
+		{name: `a\100b.example.org.`, want: "a@b.example.org."},
+	}
+	for _, tt := range tests {
+		if got := unescapeName(tt.name); got != tt.want {
+			t.Errorf("unescapeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
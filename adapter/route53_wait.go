@@ -0,0 +1,116 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+const (
+	defaultWaitInitialDelay = time.Second
+	defaultWaitMaxDelay     = 30 * time.Second
+	defaultWaitTimeout      = 15 * time.Minute
+)
+
+// WaitOptions configures how Route53.Process waits for submitted changes to become INSYNC.
+type WaitOptions struct {
+	// Skip returns as soon as a change is submitted.
+	// Note that the next action group may then reference records that are still PENDING.
+	Skip bool
+	// InitialDelay is the delay between the first polls. Defaults to 1 second.
+	InitialDelay time.Duration
+	// MaxDelay caps the exponentially growing delay between polls. Defaults to 30 seconds.
+	MaxDelay time.Duration
+	// Timeout is the overall time to wait for a single change. Defaults to 15 minutes.
+	Timeout time.Duration
+	// OnProgress is called after every poll. It's called concurrently
+	// for changes of different zones.
+	OnProgress func(ChangeProgress)
+}
+
+// ChangeProgress describes the state of a submitted change.
+type ChangeProgress struct {
+	ChangeID string
+	ZoneID   string
+	Status   types.ChangeStatus
+	Elapsed  time.Duration
+}
+
+// WithWait configures waiting for changes to become INSYNC.
+func WithWait(w WaitOptions) Route53Option {
+	return func(o *route53Options) {
+		o.wait = w
+	}
+}
+
+// GetChangeAPIClient is a client that implements the GetChange operation.
+type GetChangeAPIClient interface {
+	GetChange(context.Context, *route53.GetChangeInput, ...func(*route53.Options)) (*route53.GetChangeOutput, error)
+}
+
+var _ GetChangeAPIClient = (*route53.Client)(nil)
+
+// wait polls the change with exponential backoff until it's INSYNC,
+// the timeout is reached or ctx is done.
+func (w WaitOptions) wait(ctx context.Context, client GetChangeAPIClient, zoneID, changeID string) error {
+	if w.Skip {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout())
+	defer cancel()
+
+	start := time.Now()
+	delay := w.initialDelay()
+	for {
+		change, err := client.GetChange(ctx, &route53.GetChangeInput{Id: &changeID})
+		if err != nil {
+			return err
+		}
+		status := change.ChangeInfo.Status
+		if w.OnProgress != nil {
+			w.OnProgress(ChangeProgress{
+				ChangeID: changeID,
+				ZoneID:   zoneID,
+				Status:   status,
+				Elapsed:  time.Since(start),
+			})
+		}
+		if status == types.ChangeStatusInsync {
+			return nil
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return fmt.Errorf("waiting for change %s in zone %s: %w", changeID, zoneID, err)
+		}
+
+		delay *= 2
+		if delay > w.maxDelay() {
+			delay = w.maxDelay()
+		}
+	}
+}
+
+func (w WaitOptions) initialDelay() time.Duration {
+	if w.InitialDelay > 0 {
+		return w.InitialDelay
+	}
+	return defaultWaitInitialDelay
+}
+
+func (w WaitOptions) maxDelay() time.Duration {
+	if w.MaxDelay > 0 {
+		return w.MaxDelay
+	}
+	return defaultWaitMaxDelay
+}
+
+func (w WaitOptions) timeout() time.Duration {
+	if w.Timeout > 0 {
+		return w.Timeout
+	}
+	return defaultWaitTimeout
+}
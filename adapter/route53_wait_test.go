@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// changeClient reports PENDING for the first pending polls, then INSYNC.
+type changeClient struct {
+	pending int
+	polls   int
+}
+
+func (c *changeClient) GetChange(ctx context.Context, input *route53.GetChangeInput, optFns ...func(*route53.Options)) (*route53.GetChangeOutput, error) {
+	c.polls++
+	status := types.ChangeStatusInsync
+	if c.polls <= c.pending {
+		status = types.ChangeStatusPending
+	}
+	return &route53.GetChangeOutput{ChangeInfo: &types.ChangeInfo{Id: input.Id, Status: status}}, nil
+}
+
+func TestWaitOptions_wait(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      WaitOptions
+		pending   int
+		wantPolls int
+		wantErr   error
+	}{{
+		name:      "in sync after backoff",
+		opts:      WaitOptions{InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond},
+		pending:   3,
+		wantPolls: 4,
+	}, {
+		name:      "skip",
+		opts:      WaitOptions{Skip: true},
+		pending:   3,
+		wantPolls: 0,
+	}, {
+		name:      "timeout",
+		opts:      WaitOptions{InitialDelay: time.Millisecond, Timeout: 20 * time.Millisecond},
+		pending:   1 << 30,
+		wantErr:   context.DeadlineExceeded,
+		wantPolls: -1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &changeClient{pending: tt.pending}
+			progress := make([]ChangeProgress, 0)
+			tt.opts.OnProgress = func(p ChangeProgress) {
+				progress = append(progress, p)
+			}
+
+			err := tt.opts.wait(context.Background(), client, "Z1", "C1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("wait() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantPolls >= 0 && client.polls != tt.wantPolls {
+				t.Errorf("wait() polled %d times, want %d", client.polls, tt.wantPolls)
+			}
+			if len(progress) != client.polls {
+				t.Errorf("wait() reported progress %d times, want %d", len(progress), client.polls)
+			}
+		})
+	}
+}
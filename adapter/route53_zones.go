@@ -0,0 +1,408 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/flood4life/dnser/config"
+)
+
+// ListTagsForResources accepts at most 10 resource IDs.
+const maxTagResources = 10
+
+// ZoneVisibility selects public or private hosted zones.
+type ZoneVisibility int
+
+// Available Zone Visibilities
+const (
+	AllZones ZoneVisibility = iota
+	PublicZones
+	PrivateZones
+)
+
+// VPC identifies an Amazon VPC.
+type VPC struct {
+	ID     string
+	Region string
+}
+
+// ZoneFilter selects the hosted zones the adapter manages.
+// Empty fields don't filter anything. A zone is managed
+// if it matches every non-empty field.
+type ZoneFilter struct {
+	// IncludeIDs are the only zone IDs to manage.
+	IncludeIDs []string
+	// ExcludeIDs are the zone IDs not to manage.
+	ExcludeIDs []string
+	// IncludeNames are the only zone names to manage.
+	IncludeNames []config.Domain
+	// ExcludeNames are the zone names not to manage.
+	ExcludeNames []config.Domain
+	// Tags must all be present on a zone with the same values.
+	Tags map[string]string
+	// Visibility selects public or private zones.
+	Visibility ZoneVisibility
+	// VPC selects only the private zones associated with the VPC.
+	VPC *VPC
+}
+
+// WithZoneFilter restricts the hosted zones the adapter lists and changes.
+func WithZoneFilter(f ZoneFilter) Route53Option {
+	return func(o *route53Options) {
+		o.zoneFilter = f
+	}
+}
+
+func (a Route53) listHostedZones(ctx context.Context) ([]hostedZone, error) {
+	var (
+		zones []hostedZone
+		err   error
+	)
+	if a.zoneFilter.VPC != nil {
+		zones, err = a.listVPCHostedZones(ctx, *a.zoneFilter.VPC)
+	} else {
+		zones, err = a.listAllHostedZones(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	zones = a.zoneFilter.filter(zones)
+	if len(a.zoneFilter.Tags) > 0 {
+		zones, err = a.filterZonesByTags(ctx, zones)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return zones, nil
+}
+
+func (a Route53) listAllHostedZones(ctx context.Context) ([]hostedZone, error) {
+	zones := make([]hostedZone, 0)
+	paginator := route53.NewListHostedZonesPaginator(a.client, listZonesInput())
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, zone := range output.HostedZones {
+			zones = append(zones, hostedZone{
+				id:      extractZoneID(*zone.Id),
+				name:    config.Domain(*zone.Name),
+				private: zone.Config != nil && zone.Config.PrivateZone,
+			})
+		}
+	}
+	return zones, nil
+}
+
+func (a Route53) listVPCHostedZones(ctx context.Context, vpc VPC) ([]hostedZone, error) {
+	zones := make([]hostedZone, 0)
+	input := &route53.ListHostedZonesByVPCInput{
+		VPCId:     &vpc.ID,
+		VPCRegion: types.VPCRegion(vpc.Region),
+	}
+	for {
+		output, err := a.client.ListHostedZonesByVPC(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, zone := range output.HostedZoneSummaries {
+			zones = append(zones, hostedZone{
+				id:      extractZoneID(*zone.HostedZoneId),
+				name:    config.Domain(*zone.Name),
+				private: true,
+			})
+		}
+		if output.NextToken == nil {
+			return zones, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func (a Route53) filterZonesByTags(ctx context.Context, zones []hostedZone) ([]hostedZone, error) {
+	tags := make(map[string]map[string]string, len(zones))
+	for start := 0; start < len(zones); start += maxTagResources {
+		end := start + maxTagResources
+		if end > len(zones) {
+			end = len(zones)
+		}
+		ids := make([]string, 0, end-start)
+		for _, zone := range zones[start:end] {
+			ids = append(ids, zone.id)
+		}
+
+		output, err := a.client.ListTagsForResources(ctx, &route53.ListTagsForResourcesInput{
+			ResourceIds:  ids,
+			ResourceType: types.TagResourceTypeHostedzone,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, set := range output.ResourceTagSets {
+			zoneTags := make(map[string]string, len(set.Tags))
+			for _, tag := range set.Tags {
+				if tag.Key != nil && tag.Value != nil {
+					zoneTags[*tag.Key] = *tag.Value
+				}
+			}
+			tags[extractZoneID(*set.ResourceId)] = zoneTags
+		}
+	}
+
+	result := make([]hostedZone, 0, len(zones))
+	for _, zone := range zones {
+		if hasTags(tags[zone.id], a.zoneFilter.Tags) {
+			result = append(result, zone)
+		}
+	}
+	return result, nil
+}
+
+func hasTags(tags, want map[string]string) bool {
+	for k, v := range want {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// filter applies every criterion of the filter except the tags,
+// which need an additional API call.
+func (f ZoneFilter) filter(zones []hostedZone) []hostedZone {
+	result := make([]hostedZone, 0, len(zones))
+	for _, zone := range zones {
+		if f.matches(zone) {
+			result = append(result, zone)
+		}
+	}
+	return result
+}
+
+func (f ZoneFilter) matches(zone hostedZone) bool {
+	switch {
+	case len(f.IncludeIDs) > 0 && !containsString(f.IncludeIDs, zone.id):
+		return false
+	case containsString(f.ExcludeIDs, zone.id):
+		return false
+	case len(f.IncludeNames) > 0 && !containsDomain(f.IncludeNames, zone.name):
+		return false
+	case containsDomain(f.ExcludeNames, zone.name):
+		return false
+	case f.Visibility == PublicZones && zone.private:
+		return false
+	case f.Visibility == PrivateZones && !zone.private:
+		return false
+	default:
+		return true
+	}
+}
+
+// checkCreatable returns an error if a new zone would not match the filter,
+// so that EnsureZones doesn't create zones the adapter can't manage afterwards.
+// The tags aren't checked, EnsureZones adds them to the new zones.
+func (f ZoneFilter) checkCreatable(zone config.Zone) error {
+	switch {
+	case len(f.IncludeIDs) > 0:
+		return errors.New("the ZoneFilter only includes zones by ID")
+	case !f.matches(hostedZone{name: zone.Name, private: zone.Private}):
+		return errors.New("the ZoneFilter excludes it")
+	case f.VPC != nil && (!zone.Private || zone.VPCID != f.VPC.ID):
+		return fmt.Errorf("the ZoneFilter only includes the zones of %s", f.VPC.ID)
+	default:
+		return nil
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsDomain(values []config.Domain, value config.Domain) bool {
+	for _, v := range values {
+		if config.Domain(fqdn(string(v))) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// delegationTTL is the TTL of the NS records that delegate a subzone.
+const delegationTTL = 172800 // 2 days, same as the NS records Route53 creates
+
+// EnsureZones creates the zones that don't exist yet and returns the names of the created ones.
+// For every public zone located under another known public zone
+// the NS records delegating to it are upserted in the parent zone.
+// Zones are processed in the order of the config, so a parent zone should come before its subzones.
+// A zone that exists but is excluded by the ZoneFilter is an error rather than created again,
+// and so is a new zone the ZoneFilter would exclude. New zones get the tags of the ZoneFilter.
+func (a Route53) EnsureZones(ctx context.Context, zones []config.Zone) ([]config.Domain, error) {
+	existing, err := a.listHostedZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.initZonesMap(existing)
+	all, err := a.listAllHostedZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	created := make([]config.Domain, 0)
+	for _, zone := range zones {
+		id, ok := a.zones[zone.Name]
+		if !ok {
+			if excluded, ok := findHostedZone(all, zone.Name, zone.Private); ok {
+				return created, fmt.Errorf("zone %s exists as %s, but the ZoneFilter excludes it", zone.Name, excluded.id)
+			}
+			if err := a.zoneFilter.checkCreatable(zone); err != nil {
+				return created, fmt.Errorf("can't create zone %s: %w", zone.Name, err)
+			}
+			id, err = a.createHostedZone(ctx, zone)
+			if err != nil {
+				return created, fmt.Errorf("creating zone %s: %w", zone.Name, err)
+			}
+			a.zones[zone.Name] = id
+			created = append(created, zone.Name)
+		}
+
+		if zone.Private {
+			continue
+		}
+		if err := a.delegate(ctx, zone.Name, id, existing); err != nil {
+			return created, fmt.Errorf("delegating zone %s: %w", zone.Name, err)
+		}
+	}
+	return created, nil
+}
+
+// findHostedZone returns the zone with the name and visibility.
+func findHostedZone(zones []hostedZone, name config.Domain, private bool) (hostedZone, bool) {
+	for _, zone := range zones {
+		if zone.name == name && zone.private == private {
+			return zone, true
+		}
+	}
+	return hostedZone{}, false
+}
+
+func (a Route53) createHostedZone(ctx context.Context, zone config.Zone) (string, error) {
+	input := &route53.CreateHostedZoneInput{
+		CallerReference: aws.String(fmt.Sprintf("dnser/%s/%d", zone.Name, time.Now().UnixNano())),
+		Name:            aws.String(string(zone.Name)),
+		HostedZoneConfig: &types.HostedZoneConfig{
+			Comment:     optionalString(zone.Comment),
+			PrivateZone: zone.Private,
+		},
+	}
+	if zone.Private {
+		input.VPC = &types.VPC{
+			VPCId:     aws.String(zone.VPCID),
+			VPCRegion: types.VPCRegion(zone.VPCRegion),
+		}
+	}
+
+	output, err := a.client.CreateHostedZone(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	id := extractZoneID(*output.HostedZone.Id)
+	if err := a.tagHostedZone(ctx, id, a.zoneFilter.Tags); err != nil {
+		return "", err
+	}
+	return id, a.wait.wait(ctx, a.client, id, extractZoneID(*output.ChangeInfo.Id))
+}
+
+// tagHostedZone adds the tags to the zone, e.g. the ones the ZoneFilter selects the zones by.
+func (a Route53) tagHostedZone(ctx context.Context, id string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	input := &route53.ChangeTagsForResourceInput{
+		ResourceId:   aws.String(id),
+		ResourceType: types.TagResourceTypeHostedzone,
+	}
+	for _, k := range keys {
+		input.AddTags = append(input.AddTags, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	_, err := a.client.ChangeTagsForResource(ctx, input)
+	return err
+}
+
+// delegate upserts the NS records of the zone in its closest public parent zone, if there is one.
+func (a Route53) delegate(ctx context.Context, name config.Domain, id string, zones []hostedZone) error {
+	parentID := parentZoneID(name, a.zones, zones)
+	if parentID == "" {
+		return nil
+	}
+
+	zone, err := a.client.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: aws.String(id)})
+	if err != nil {
+		return err
+	}
+	if zone.DelegationSet == nil || len(zone.DelegationSet.NameServers) == 0 {
+		return fmt.Errorf("zone %s has no name servers", name)
+	}
+
+	records := make([]types.ResourceRecord, len(zone.DelegationSet.NameServers))
+	for i, ns := range zone.DelegationSet.NameServers {
+		records[i] = types.ResourceRecord{Value: aws.String(fqdn(ns))}
+	}
+	res, err := a.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(parentID),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: aws.String("dnser: delegate " + string(name)),
+			Changes: []types.Change{{
+				Action: types.ChangeActionUpsert,
+				ResourceRecordSet: &types.ResourceRecordSet{
+					Name:            aws.String(string(name)),
+					Type:            types.RRTypeNs,
+					TTL:             aws.Int64(delegationTTL),
+					ResourceRecords: records,
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return a.wait.wait(ctx, a.client, parentID, extractZoneID(*res.ChangeInfo.Id))
+}
+
+// parentZoneID returns the ID of the closest public zone above name, or "" if there is none.
+// Zones that are not in listed were created by EnsureZones, which only delegates public zones.
+func parentZoneID(name config.Domain, known map[config.Domain]string, listed []hostedZone) string {
+	private := make(map[string]bool, len(listed))
+	for _, zone := range listed {
+		private[zone.id] = zone.private
+	}
+
+	best, id := config.Domain(""), ""
+	for zone, zoneID := range known {
+		if zone == name || !isSubdomain(name, zone) || private[zoneID] {
+			continue
+		}
+		if len(zone) > len(best) {
+			best, id = zone, zoneID
+		}
+	}
+	return id
+}
@@ -0,0 +1,117 @@
+package adapter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flood4life/dnser/config"
+)
+
+var testZones = []hostedZone{
+	{id: "Z1", name: "example.org.", private: false},
+	{id: "Z2", name: "example.org.", private: true},
+	{id: "Z3", name: "example.com.", private: false},
+}
+
+func TestZoneFilter_filter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ZoneFilter
+		want   []hostedZone
+	}{{
+		name:   "no filter",
+		filter: ZoneFilter{},
+		want:   testZones,
+	}, {
+		name:   "public zones",
+		filter: ZoneFilter{Visibility: PublicZones},
+		want:   []hostedZone{testZones[0], testZones[2]},
+	}, {
+		name:   "private zones",
+		filter: ZoneFilter{Visibility: PrivateZones},
+		want:   []hostedZone{testZones[1]},
+	}, {
+		name:   "include names without trailing dot",
+		filter: ZoneFilter{IncludeNames: []config.Domain{"example.com"}},
+		want:   []hostedZone{testZones[2]},
+	}, {
+		name:   "exclude IDs",
+		filter: ZoneFilter{ExcludeIDs: []string{"Z1", "Z3"}},
+		want:   []hostedZone{testZones[1]},
+	}, {
+		name:   "include IDs and exclude names",
+		filter: ZoneFilter{IncludeIDs: []string{"Z1", "Z3"}, ExcludeNames: []config.Domain{"example.org."}},
+		want:   []hostedZone{testZones[2]},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.filter(testZones); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute53_zoneIDForName(t *testing.T) {
+	a := Route53{
+		zones: map[config.Domain]string{
+			"example.org.":     "Z1",
+			"dev.example.org.": "Z2",
+			"example.net.":     "Z4",
+		},
+		// a public and a private zone, e.g. split-horizon DNS
+		sharedZoneNames: map[config.Domain]string{"example.net.": "Z3"},
+	}
+	tests := []struct {
+		name    config.Domain
+		want    string
+		wantErr bool
+	}{
+		{name: "example.org.", want: "Z1"},
+		{name: "www.example.org.", want: "Z1"},
+		{name: "dev.example.org.", want: "Z2"},
+		{name: "app.dev.example.org.", want: "Z2"},
+		{name: "example.com.", wantErr: true},
+		{name: "www.example.net.", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.name), func(t *testing.T) {
+			got, err := a.zoneIDForName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("zoneIDForName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("zoneIDForName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentZoneID(t *testing.T) {
+	known := map[config.Domain]string{
+		"example.org.":         "Z1",
+		"dev.example.org.":     "Z2",
+		"app.dev.example.org.": "Z3",
+	}
+	listed := []hostedZone{
+		{id: "Z1", name: "example.org."},
+		{id: "Z2", name: "dev.example.org.", private: true},
+	}
+	tests := []struct {
+		name config.Domain
+		want string
+	}{
+		{name: "example.org.", want: ""},
+		{name: "dev.example.org.", want: "Z1"},
+		// the private dev.example.org. can't delegate
+		{name: "app.dev.example.org.", want: "Z1"},
+		{name: "example.com.", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.name), func(t *testing.T) {
+			if got := parentZoneID(tt.name, known, listed); got != tt.want {
+				t.Errorf("parentZoneID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
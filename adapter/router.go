@@ -0,0 +1,141 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+	"golang.org/x/sync/errgroup"
+)
+
+// Route maps the names ending with Suffix to an Adapter.
+type Route struct {
+	Suffix  config.Domain
+	Adapter dnser.Adapter
+}
+
+// Router is an Adapter that delegates each record to the Adapter
+// of the route with the longest matching suffix.
+type Router struct {
+	routes []Route
+}
+
+// NewRouter constructs a Router instance from the routes.
+// Suffixes are matched on label boundaries, so "example.org" matches
+// "example.org." and "foo.example.org.", but not "myexample.org.".
+func NewRouter(routes ...Route) Router {
+	normalized := make([]Route, len(routes))
+	for i, route := range routes {
+		normalized[i] = Route{
+			Suffix:  config.Domain(fqdn(string(route.Suffix))),
+			Adapter: route.Adapter,
+		}
+	}
+	return Router{routes: normalized}
+}
+
+// List merges the records of every routed Adapter.
+// Records that don't belong to the route they were listed from are dropped,
+// so an Adapter that is routed several suffixes doesn't report its records twice.
+func (a Router) List(ctx context.Context) ([]dnser.DNSRecord, error) {
+	g, gCtx := errgroup.WithContext(ctx)
+	records := make([][]dnser.DNSRecord, len(a.routes))
+
+	for i, route := range a.routes {
+		i, route := i, route
+		g.Go(func() error {
+			routeRecords, err := route.Adapter.List(gCtx)
+			if err != nil {
+				return fmt.Errorf("listing %s: %w", route.Suffix, err)
+			}
+			records[i] = a.filterOwnRecords(i, routeRecords)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return flattenRecords(records), nil
+}
+
+func (a Router) filterOwnRecords(routeIndex int, records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0, len(records))
+	for _, r := range records {
+		if i, ok := a.routeIndex(r.Name); ok && i == routeIndex {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// Process splits every action group by route and delegates the parts
+// to the routed Adapters concurrently.
+// A group is only started once the previous group was processed by all Adapters,
+// so the ordering computed by the massager holds across Adapters.
+// Every group is a Process call of its own, so an Adapter that rolls back on failure,
+// e.g. Route53 WithRollback, only reverts the failed group: the ordering wins over the rollback.
+// The actions are checked for a route before any Adapter is called.
+func (a Router) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	splitGroups := make([][][]dnser.Action, len(actionGroups))
+	for i, actions := range actionGroups {
+		split, err := a.splitActions(actions)
+		if err != nil {
+			return err
+		}
+		splitGroups[i] = split
+	}
+
+	for _, split := range splitGroups {
+		g, gCtx := errgroup.WithContext(ctx)
+		for i, routeActions := range split {
+			if len(routeActions) == 0 {
+				continue
+			}
+			route, routeActions := a.routes[i], routeActions
+			g.Go(func() error {
+				if err := route.Adapter.Process(gCtx, [][]dnser.Action{routeActions}); err != nil {
+					return fmt.Errorf("processing %s: %w", route.Suffix, err)
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a Router) splitActions(actions []dnser.Action) ([][]dnser.Action, error) {
+	result := make([][]dnser.Action, len(a.routes))
+	for _, action := range actions {
+		i, ok := a.routeIndex(action.Record.Name)
+		if !ok {
+			return nil, fmt.Errorf("no route for %s", action.Record.Name)
+		}
+		result[i] = append(result[i], action)
+	}
+	return result, nil
+}
+
+// routeIndex returns the index of the route with the longest suffix matching domain.
+func (a Router) routeIndex(domain config.Domain) (int, bool) {
+	best, found := 0, false
+	for i, route := range a.routes {
+		if !isSubdomain(domain, route.Suffix) {
+			continue
+		}
+		if !found || len(route.Suffix) > len(a.routes[best].Suffix) {
+			best, found = i, true
+		}
+	}
+	return best, found
+}
+
+// isSubdomain returns whether domain equals parent or is located under it.
+func isSubdomain(domain, parent config.Domain) bool {
+	return domain == parent || strings.HasSuffix(string(domain), "."+string(parent))
+}
@@ -0,0 +1,136 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+func TestRouter_Process(t *testing.T) {
+	org := &memoryAdapter{}
+	internal := &memoryAdapter{}
+	a := NewRouter(
+		Route{Suffix: "example.org", Adapter: org},
+		Route{Suffix: "internal.example.org.", Adapter: internal},
+	)
+
+	groups := [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("example.org.", "127.0.0.1"),
+	}, {
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("internal.example.org.", "10.0.0.1"),
+	}}, {{
+		Type:   dnser.Upsert,
+		Record: dnser.NewAliasRecord("api.internal.example.org.", "internal.example.org."),
+	}}}
+	if err := a.Process(context.Background(), groups); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	wantOrg := [][]dnser.Action{{groups[0][0]}}
+	if !reflect.DeepEqual(org.processed, wantOrg) {
+		t.Errorf("example.org processed = %v, want %v", org.processed, wantOrg)
+	}
+	wantInternal := [][]dnser.Action{{groups[0][1]}, {groups[1][0]}}
+	if !reflect.DeepEqual(internal.processed, wantInternal) {
+		t.Errorf("internal.example.org processed = %v, want %v", internal.processed, wantInternal)
+	}
+
+	got, err := a.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []dnser.DNSRecord{
+		dnser.NewRecord("example.org.", "127.0.0.1"),
+		dnser.NewRecord("internal.example.org.", "10.0.0.1"),
+		dnser.NewAliasRecord("api.internal.example.org.", "internal.example.org."),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() got = %v, want %v", got, want)
+	}
+}
+
+// slowAdapter is a memoryAdapter that takes a while to process the actions.
+type slowAdapter struct {
+	memoryAdapter
+}
+
+func (s *slowAdapter) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	time.Sleep(20 * time.Millisecond)
+	return s.memoryAdapter.Process(ctx, actionGroups)
+}
+
+// targetCheckingAdapter is a memoryAdapter that fails to process an alias whose target isn't listed by targets.
+type targetCheckingAdapter struct {
+	memoryAdapter
+	targets dnser.Adapter
+}
+
+func (c *targetCheckingAdapter) Process(ctx context.Context, actionGroups [][]dnser.Action) error {
+	records, err := c.targets.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, actions := range actionGroups {
+		for _, action := range actions {
+			if action.Record.Alias && !hasRecordNamed(records, action.Record.Target) {
+				return fmt.Errorf("alias %s to missing target %s", action.Record.Name, action.Record.Target)
+			}
+		}
+	}
+	return c.memoryAdapter.Process(ctx, actionGroups)
+}
+
+func hasRecordNamed(records []dnser.DNSRecord, name config.Domain) bool {
+	for _, r := range records {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRouter_ProcessAcrossRoutes(t *testing.T) {
+	org := &slowAdapter{}
+	net := &targetCheckingAdapter{targets: org}
+	a := NewRouter(
+		Route{Suffix: "example.org", Adapter: org},
+		Route{Suffix: "example.net", Adapter: net},
+	)
+
+	// the alias in example.net. may only be created once its target in example.org. exists
+	err := a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("example.org.", "127.0.0.1"),
+	}}, {{
+		Type:   dnser.Upsert,
+		Record: dnser.NewAliasRecord("www.example.net.", "example.org."),
+	}}})
+	if err != nil {
+		t.Errorf("Process() error = %v", err)
+	}
+}
+
+func TestRouter_ProcessNoRoute(t *testing.T) {
+	org := &memoryAdapter{}
+	a := NewRouter(Route{Suffix: "example.org", Adapter: org})
+	err := a.Process(context.Background(), [][]dnser.Action{{{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("example.org.", "127.0.0.1"),
+	}}, {{
+		Type:   dnser.Upsert,
+		Record: dnser.NewRecord("myexample.org.", "127.0.0.1"),
+	}}})
+	if err == nil {
+		t.Error("Process() expected an error for an unrouted name")
+	}
+	if org.calls != 0 {
+		t.Errorf("example.org processed %d calls, want none before the error", org.calls)
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import "fmt"
+
+// AliasTargetType is the kind of AWS resource an Item can alias instead of pointing to an IP.
+type AliasTargetType string
+
+// Available Alias Target Types
+const (
+	// ELB is a Classic or an Application Load Balancer.
+	ELB AliasTargetType = "elb"
+	// NLB is a Network Load Balancer.
+	NLB AliasTargetType = "nlb"
+	// CloudFront is a CloudFront distribution or an edge-optimized API Gateway.
+	CloudFront AliasTargetType = "cloudfront"
+	// S3Website is an S3 website endpoint.
+	S3Website AliasTargetType = "s3"
+	// APIGateway is a regional API Gateway custom domain name.
+	APIGateway AliasTargetType = "apigateway"
+	// HostedZone is a record in another hosted zone.
+	HostedZone AliasTargetType = "zone"
+)
+
+// cloudFrontZoneID is the hosted zone ID of every CloudFront distribution.
+const cloudFrontZoneID = "Z2FDTNDATAQYW2"
+
+// canonicalZoneIDs maps alias target types to the hosted zone IDs of their endpoints per region.
+var canonicalZoneIDs = map[AliasTargetType]map[string]string{
+	ELB: {
+		"us-east-1":      "Z35SXDOTRQ7X7K",
+		"us-east-2":      "Z3AADJGX6KTTL2",
+		"us-west-1":      "Z368ELLRRE2KJ0",
+		"us-west-2":      "Z1H1FL5HABSF5",
+		"ca-central-1":   "ZQSVJUPU6J1EY",
+		"eu-central-1":   "Z215JYRZR1TBD5",
+		"eu-west-1":      "Z32O12XQLNTSW2",
+		"eu-west-2":      "ZHURV8PSTC4K8",
+		"eu-west-3":      "Z3Q77PNBQS71R4",
+		"eu-north-1":     "Z23TAZ7KICR5AM",
+		"ap-northeast-1": "Z14GRHDCWA56QT",
+		"ap-northeast-2": "ZWKZPGTI48KDX",
+		"ap-southeast-1": "Z1LMS91P8CMLE5",
+		"ap-southeast-2": "Z1GM3OXH4ZPM65",
+		"ap-south-1":     "ZP97RAFLXTNZK",
+		"sa-east-1":      "Z2P70J7HTTTPLU",
+	},
+	NLB: {
+		"us-east-1":      "Z26RNL4JYFTOTI",
+		"us-east-2":      "ZLMOA37VPKANP",
+		"us-west-1":      "Z24FKFUX50B4VW",
+		"us-west-2":      "Z18D5FSROUN65G",
+		"ca-central-1":   "Z2EPGBW3API2WT",
+		"eu-central-1":   "Z3F0SRJ5LGBH90",
+		"eu-west-1":      "Z2IFOLAFXWLO4F",
+		"eu-west-2":      "ZD4D7Y8KGAS4G",
+		"eu-west-3":      "Z1CMS0P5QUZ6D5",
+		"eu-north-1":     "Z1UDT6IFJ4EJM",
+		"ap-northeast-1": "Z31USIVHYNEOWT",
+		"ap-northeast-2": "ZIBE1TIR4HY56",
+		"ap-southeast-1": "ZKVM4W9LS7TM",
+		"ap-southeast-2": "ZCT6FZBF4DROD",
+		"ap-south-1":     "ZVDDRBQ08TROA",
+		"sa-east-1":      "ZTK26PT1VY4CU",
+	},
+	S3Website: {
+		"us-east-1":      "Z3AQBSTGFYJSTF",
+		"us-east-2":      "Z2O1EMRO9K5GLX",
+		"us-west-1":      "Z2F56UZL2M1ACD",
+		"us-west-2":      "Z3BJ6K6RIION7M",
+		"ca-central-1":   "Z1QDHH18159H29",
+		"eu-central-1":   "Z21DNDUVLTQW6Q",
+		"eu-west-1":      "Z1BKCTXD74EZPE",
+		"eu-west-2":      "Z3GKZC51ZF0DB4",
+		"eu-west-3":      "Z3R1K369G5AVDG",
+		"eu-north-1":     "Z3BAZG2TWCNX0D",
+		"ap-northeast-1": "Z2M4EHUR26P7ZW",
+		"ap-northeast-2": "Z3W03O7B5YMIYP",
+		"ap-southeast-1": "Z3O0J2DXBE1FTB",
+		"ap-southeast-2": "Z1WCIGYICN2BYD",
+		"ap-south-1":     "Z11RGJOFQNVJUP",
+		"sa-east-1":      "Z7KQH4QJS55SO",
+	},
+	APIGateway: {
+		"us-east-1":      "Z1UJRXOUMOOFQ8",
+		"us-east-2":      "ZOJJZC49E0EPZ",
+		"us-west-1":      "Z2MUQ32089INYE",
+		"us-west-2":      "Z2OJLYMUO9EFXC",
+		"ca-central-1":   "Z19DQILCV0OWEC",
+		"eu-central-1":   "Z1U9ULNL0V5AJ3",
+		"eu-west-1":      "ZLY8HYME6SFDD",
+		"eu-west-2":      "ZJ5UAJN8Y3Z2Q",
+		"eu-west-3":      "Z3KY65QIEKYHQQ",
+		"eu-north-1":     "Z3UWIKFBOOGXPP",
+		"ap-northeast-1": "Z1YSHQZHG15GKL",
+		"ap-northeast-2": "Z20JF4UZKIW1U8",
+		"ap-southeast-1": "ZL327KTPIQFUL",
+		"ap-southeast-2": "Z2RPCDW04V8134",
+		"ap-south-1":     "Z3VO1THU9YC4UR",
+		"sa-east-1":      "ZCMLWB8V5SYIT",
+	},
+}
+
+// AliasTarget is an AWS resource that an Item aliases.
+type AliasTarget struct {
+	Type    AliasTargetType
+	DNSName Domain
+	// Region of the resource. Required for every type except CloudFront and HostedZone.
+	Region string
+	// HostedZoneID overrides the canonical hosted zone ID of the resource.
+	// For HostedZone it may be left empty to use the zone that hosts DNSName.
+	HostedZoneID string
+	// EvaluateTargetHealth makes Route53 check the health of the resource.
+	// It defaults to true, like for the records of the alias trees,
+	// except for CloudFront, whose distributions Route53 can't check.
+	EvaluateTargetHealth bool
+}
+
+// CanonicalHostedZoneID returns the hosted zone ID Route53 expects for the alias target.
+// An empty ID means the zone that hosts DNSName.
+func (t AliasTarget) CanonicalHostedZoneID() (string, error) {
+	if t.HostedZoneID != "" {
+		return t.HostedZoneID, nil
+	}
+
+	switch t.Type {
+	case CloudFront:
+		return cloudFrontZoneID, nil
+	case HostedZone:
+		return "", nil
+	}
+
+	regions, ok := canonicalZoneIDs[t.Type]
+	if !ok {
+		return "", fmt.Errorf("unknown alias target type %q", t.Type)
+	}
+	id, ok := regions[t.Region]
+	if !ok {
+		return "", fmt.Errorf("unknown region %q for alias target type %q, set hostedZoneId explicitly", t.Region, t.Type)
+	}
+	return id, nil
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFromFile creates a config from a file and the files it includes.
+//
+// The top-level include is a list of paths or globs relative to the file, e.g. teams/*.yaml.
+// The included files may include further files; every file is loaded once.
+// All files must have the same apiVersion, and at most one of them may set the defaults.
+// Their items and top-level sections are merged, in the order the files are included.
+func LoadFromFile(path string) (Config, error) {
+	docs, err := readDocuments([]string{path}, make(map[string]bool))
+	if err != nil {
+		return Config{}, err
+	}
+	return loadDocuments(docs)
+}
+
+// LoadFromDir creates a config from the .yaml and .yml files of a directory, in the order of their names,
+// like LoadFromFile from a file that includes all of them.
+func LoadFromDir(dir string) (Config, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return Config{}, err
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml":
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return Config{}, fmt.Errorf("%s: no .yaml or .yml files", dir)
+	}
+
+	docs, err := readDocuments(paths, make(map[string]bool))
+	if err != nil {
+		return Config{}, err
+	}
+	return loadDocuments(docs)
+}
+
+// document is the data of a config file.
+type document struct {
+	// path is empty for configs that aren't loaded from a file.
+	path   string
+	data   []byte
+	header yamlHeader
+}
+
+// yamlHeader is the part of a config file that is read before the file is merged with others.
+type yamlHeader struct {
+	APIVersion APIVersion `yaml:"apiVersion"`
+	Include    []string   `yaml:"include"`
+	Defaults   yaml.Node  `yaml:"defaults"`
+}
+
+func parseDocument(path string, data []byte) (document, error) {
+	doc := document{path: path, data: data}
+	if err := yaml.Unmarshal(data, &doc.header); err != nil {
+		return document{}, doc.wrap(err)
+	}
+	return doc, nil
+}
+
+// wrap prefixes err with the path of the document.
+func (d document) wrap(err error) error {
+	if d.path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", d.path, err)
+}
+
+// readDocuments reads the files and the files they include, depth first.
+// loaded contains the absolute paths of the files that are already read.
+func readDocuments(paths []string, loaded map[string]bool) ([]document, error) {
+	result := make([]document, 0, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if loaded[abs] {
+			continue
+		}
+		loaded[abs] = true
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := parseDocument(path, data)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, doc)
+
+		included, err := includedPaths(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs, err := readDocuments(included, loaded)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, docs...)
+	}
+	return result, nil
+}
+
+// includedPaths expands the include list of a document relative to its directory.
+func includedPaths(doc document) ([]string, error) {
+	dir := filepath.Dir(doc.path)
+	result := make([]string, 0, len(doc.header.Include))
+	for _, pattern := range doc.header.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, doc.wrap(fmt.Errorf("include %s: %w", pattern, err))
+		}
+		// a glob may match nothing, e.g. before the first team adds its file
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, doc.wrap(fmt.Errorf("include %s: no such file", pattern))
+		}
+		sort.Strings(matches)
+		result = append(result, matches...)
+	}
+	return result, nil
+}
+
+// nameSource is where a name is declared.
+type nameSource struct {
+	path string
+	line int
+}
+
+func (s nameSource) String() string {
+	return fmt.Sprintf("%s:%d", s.path, s.line)
+}
+
+// checkDuplicateNames checks that no domain or alias is declared in more than one document,
+// so that every name is owned by a single file.
+// Names declared more than once in the same document, e.g. with routing policies, are left to the loader.
+func checkDuplicateNames(docs []document) error {
+	if len(docs) < 2 {
+		return nil
+	}
+	seen := make(map[Domain]nameSource)
+	for _, doc := range docs {
+		names, err := declaredNames(doc)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			first, ok := seen[name.value]
+			if !ok {
+				seen[name.value] = name.source
+				continue
+			}
+			if first.path != doc.path {
+				return fmt.Errorf("%s is declared in %s and %s", name.value, first, name.source)
+			}
+		}
+	}
+	return nil
+}
+
+type declaredName struct {
+	value  Domain
+	source nameSource
+}
+
+// declaredNames returns the domains and aliases of the items of a document with their lines.
+func declaredNames(doc document) ([]declaredName, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(doc.data, &root); err != nil {
+		return nil, doc.wrap(err)
+	}
+	if len(root.Content) == 0 {
+		return nil, nil // empty file
+	}
+
+	result := make([]declaredName, 0)
+	add := func(n *yaml.Node) {
+		result = append(result, declaredName{
+			value:  domainOfString(n.Value),
+			source: nameSource{path: doc.path, line: n.Line},
+		})
+	}
+	items := mappingValue(root.Content[0], "config")
+	if doc.header.APIVersion == Two {
+		items = mappingValue(root.Content[0], "items")
+	}
+	if items == nil {
+		return nil, nil
+	}
+	for _, item := range items.Content {
+		if domain := mappingValue(item, "domain"); domain != nil {
+			add(domain)
+		}
+		if aliases := mappingValue(item, "aliases"); aliases != nil {
+			for _, n := range aliases.Content {
+				walkAliasNames(n, add)
+			}
+		}
+	}
+	return result, nil
+}
+
+// walkAliasNames calls f with the node of every name of an alias tree, like nodeFromYaml reads them.
+func walkAliasNames(node *yaml.Node, f func(*yaml.Node)) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		f(node)
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			walkAliasNames(child, f)
+		}
+	case yaml.MappingNode:
+		f(node.Content[0])
+		for _, child := range node.Content[1].Content {
+			walkAliasNames(child, f)
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
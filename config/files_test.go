@@ -0,0 +1,184 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files into a new directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const dataRoot = `apiVersion: 2
+include:
+- teams/*.yaml
+defaults:
+  ttl: 60
+providers:
+- name: route53
+  type: route53
+healthChecks:
+- name: web
+  type: HTTPS
+  fqdn: example.org
+`
+
+const dataTeamWeb = `apiVersion: 2
+items:
+- ip: 10.0.0.1
+  domain: example.org
+  provider: route53
+  routing:
+    setIdentifier: primary
+    failover: PRIMARY
+    healthCheck: web
+  aliases:
+  - www.example.org
+`
+
+const dataTeamAPI = `apiVersion: 2
+items:
+- ip: 10.0.0.2
+  domain: api.example.org
+records:
+- name: api.example.org
+  type: TXT
+  values:
+  - owner=api
+`
+
+func TestLoadFromFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"dnser.yaml":     dataRoot,
+		"teams/web.yaml": dataTeamWeb,
+		"teams/api.yaml": dataTeamAPI,
+	})
+	got, err := LoadFromFile(filepath.Join(dir, "dnser.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+
+	// the teams are included in the order of their names
+	domains := make([]Domain, len(got.Config))
+	for i, item := range got.Config {
+		domains[i] = item.Domain
+	}
+	if want := []Domain{"api.example.org.", "example.org."}; !reflect.DeepEqual(domains, want) {
+		t.Errorf("LoadFromFile() domains = %v, want %v", domains, want)
+	}
+	// the defaults, providers and health checks of the root apply to the included files
+	if got.Config[0].TTL != 60 || got.Records[0].TTL != 60 {
+		t.Errorf("LoadFromFile() TTLs = %d, %d, want the default 60", got.Config[0].TTL, got.Records[0].TTL)
+	}
+	if got.Config[1].Routing.HealthCheck != "web" || len(got.Providers) != 1 {
+		t.Errorf("LoadFromFile() = %v, want the health check and provider of the root", got)
+	}
+}
+
+func TestLoadFromDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"web.yaml":   dataTeamWeb + "providers:\n- name: route53\n  type: route53\n" + "healthChecks:\n- name: web\n  type: HTTPS\n  fqdn: example.org\n",
+		"api.yml":    dataTeamAPI,
+		"README.md":  "not a config",
+		"old/x.yaml": "apiVersion: 1\n",
+	})
+	got, err := LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir() error = %v", err)
+	}
+	if len(got.Config) != 2 || got.Config[0].Domain != "api.example.org." || got.Config[1].Domain != "example.org." {
+		t.Errorf("LoadFromDir() items = %v, want api.example.org. and example.org.", got.Config)
+	}
+}
+
+func TestLoadFromFile_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr []string
+	}{{
+		name: "duplicate domain",
+		files: map[string]string{
+			"dnser.yaml": "apiVersion: 1\ninclude: [other.yaml]\nconfig:\n- ip: 10.0.0.1\n  domain: example.org\n",
+			"other.yaml": "apiVersion: 1\nconfig:\n- ip: 10.0.0.2\n  domain: example.org\n",
+		},
+		wantErr: []string{"example.org.", "dnser.yaml:5", "other.yaml:4"},
+	}, {
+		name: "domain is an alias of another file",
+		files: map[string]string{
+			"dnser.yaml": "apiVersion: 1\ninclude: [other.yaml]\nconfig:\n- ip: 10.0.0.1\n  domain: example.org\n  aliases:\n  - www.example.org:\n    - app.example.org\n",
+			"other.yaml": "apiVersion: 1\nconfig:\n- ip: 10.0.0.2\n  domain: app.example.org\n",
+		},
+		wantErr: []string{"app.example.org.", "dnser.yaml:8", "other.yaml:4"},
+	}, {
+		name: "different apiVersions",
+		files: map[string]string{
+			"dnser.yaml": "apiVersion: 1\ninclude: [other.yaml]\n",
+			"other.yaml": "apiVersion: 2\n",
+		},
+		wantErr: []string{"other.yaml", "apiVersion"},
+	}, {
+		name: "defaults in two files",
+		files: map[string]string{
+			"dnser.yaml": "apiVersion: 2\ninclude: [other.yaml]\ndefaults:\n  ttl: 60\n",
+			"other.yaml": "apiVersion: 2\ndefaults:\n  ttl: 300\n",
+		},
+		wantErr: []string{"other.yaml", "defaults"},
+	}, {
+		name: "missing include",
+		files: map[string]string{
+			"dnser.yaml": "apiVersion: 1\ninclude: [missing.yaml]\n",
+		},
+		wantErr: []string{"missing.yaml"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := LoadFromFile(filepath.Join(dir, "dnser.yaml"))
+			if err == nil {
+				t.Fatal("LoadFromFile() error = nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadFromFile() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadFromFile_includeCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"dnser.yaml": "apiVersion: 1\ninclude: [other.yaml]\nconfig:\n- ip: 10.0.0.1\n  domain: example.org\n",
+		"other.yaml": "apiVersion: 1\ninclude: [dnser.yaml]\nconfig:\n- ip: 10.0.0.2\n  domain: app.example.org\n",
+	})
+	got, err := LoadFromFile(filepath.Join(dir, "dnser.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if len(got.Config) != 2 {
+		t.Errorf("LoadFromFile() items = %v, want every file once", got.Config)
+	}
+}
+
+func TestLoadFromString_include(t *testing.T) {
+	if _, err := LoadFromString("apiVersion: 1\ninclude: [other.yaml]\n"); err == nil {
+		t.Error("LoadFromString() error = nil, want an error for include")
+	}
+}
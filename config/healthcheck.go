@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HealthCheckType is the protocol a health check uses.
+type HealthCheckType string
+
+// Available Health Check Types
+const (
+	HTTP  HealthCheckType = "HTTP"
+	HTTPS HealthCheckType = "HTTPS"
+	TCP   HealthCheckType = "TCP"
+)
+
+// Health check defaults, the same as Route53's.
+const (
+	defaultHealthCheckInterval         = 30
+	defaultHealthCheckFailureThreshold = 3
+)
+
+// maxHealthCheckNameLength keeps the caller reference "dnser/<name>/<timestamp>" of a Route53 health check
+// within Route53's limit of 64 characters.
+const maxHealthCheckNameLength = 38
+
+// HealthCheck is an endpoint health check that records refer to by Name.
+// At least one of IP and FQDN must be set.
+type HealthCheck struct {
+	Name string
+	Type HealthCheckType
+
+	IP   IP
+	FQDN Domain
+	Port int32
+	Path string // HTTP and HTTPS only
+
+	Interval         int32 // seconds, 10 or 30
+	FailureThreshold int32
+}
+
+type yamlHealthCheck struct {
+	Name             string          `yaml:"name"`
+	Type             HealthCheckType `yaml:"type"`
+	IP               IP              `yaml:"ip"`
+	FQDN             string          `yaml:"fqdn"`
+	Port             int32           `yaml:"port"`
+	Path             string          `yaml:"path"`
+	Interval         int32           `yaml:"interval"`
+	FailureThreshold int32           `yaml:"failureThreshold"`
+}
+
+func healthCheckFromYaml(yh yamlHealthCheck) (HealthCheck, error) {
+	hc := HealthCheck{
+		Name:             yh.Name,
+		Type:             HealthCheckType(strings.ToUpper(string(yh.Type))),
+		IP:               yh.IP,
+		Port:             yh.Port,
+		Path:             yh.Path,
+		Interval:         yh.Interval,
+		FailureThreshold: yh.FailureThreshold,
+	}
+	if yh.FQDN != "" {
+		hc.FQDN = domainOfString(yh.FQDN)
+	}
+
+	if hc.Name == "" {
+		return HealthCheck{}, fmt.Errorf("health check name must be set")
+	}
+	if len(hc.Name) > maxHealthCheckNameLength {
+		return HealthCheck{}, fmt.Errorf("health check %s: name must be at most %d characters", hc.Name, maxHealthCheckNameLength)
+	}
+	if hc.IP == "" && hc.FQDN == "" {
+		return HealthCheck{}, fmt.Errorf("health check %s: at least one of ip and fqdn must be set", hc.Name)
+	}
+
+	switch hc.Type {
+	case HTTP:
+		hc.Port = defaultInt32(hc.Port, 80)
+		hc.Path = defaultString(hc.Path, "/")
+	case HTTPS:
+		hc.Port = defaultInt32(hc.Port, 443)
+		hc.Path = defaultString(hc.Path, "/")
+	case TCP:
+		if hc.Port == 0 {
+			return HealthCheck{}, fmt.Errorf("health check %s: TCP requires a port", hc.Name)
+		}
+		if hc.Path != "" {
+			return HealthCheck{}, fmt.Errorf("health check %s: TCP does not support a path", hc.Name)
+		}
+	default:
+		return HealthCheck{}, fmt.Errorf("health check %s: unknown type %q", hc.Name, yh.Type)
+	}
+
+	hc.Interval = defaultInt32(hc.Interval, defaultHealthCheckInterval)
+	if hc.Interval != 10 && hc.Interval != 30 {
+		return HealthCheck{}, fmt.Errorf("health check %s: interval must be 10 or 30", hc.Name)
+	}
+	hc.FailureThreshold = defaultInt32(hc.FailureThreshold, defaultHealthCheckFailureThreshold)
+	return hc, nil
+}
+
+// WithHealthCheckIDs returns a copy of the config where the health check names
+// that items refer to are replaced by the IDs the provider assigned to them.
+func (c Config) WithHealthCheckIDs(ids map[string]string) (Config, error) {
+	items := make([]Item, len(c.Config))
+	for i, item := range c.Config {
+		if item.Routing.HealthCheck != "" {
+			id, ok := ids[item.Routing.HealthCheck]
+			if !ok {
+				return Config{}, fmt.Errorf("item %s: unknown health check %q", item.Domain, item.Routing.HealthCheck)
+			}
+			item.Routing.HealthCheckID = id
+			item.Routing.HealthCheck = ""
+		}
+		items[i] = item
+	}
+	c.Config = items
+	return c, nil
+}
+
+func defaultInt32(v, def int32) int32 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func defaultString(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
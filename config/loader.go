@@ -2,7 +2,9 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -10,62 +12,278 @@ import (
 
 // LoadFromString creates a config from a string.
 func LoadFromString(data string) (Config, error) {
-	var yc yamlConfig
-	if err := yaml.Unmarshal([]byte(data), &yc); err != nil {
-		return Config{}, err
-	}
-
-	return processLoadedYaml(yc)
+	return load([]byte(data))
 }
 
 // LoadFromReader creates a config from a io.Reader.
 func LoadFromReader(r io.Reader) (Config, error) {
-	var yc yamlConfig
-	if err := yaml.NewDecoder(r).Decode(&yc); err != nil {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
 		return Config{}, err
 	}
+	return load(data)
+}
 
-	return processLoadedYaml(yc)
+// load loads the data of a single config.
+func load(data []byte) (Config, error) {
+	doc, err := parseDocument("", data)
+	if err != nil {
+		return Config{}, err
+	}
+	if len(doc.header.Include) > 0 {
+		return Config{}, errors.New("include is only supported by LoadFromFile and LoadFromDir")
+	}
+	return loadDocuments([]document{doc})
 }
 
-func processLoadedYaml(cfg yamlConfig) (Config, error) {
-	if cfg.APIVersion != One {
-		return Config{}, errors.New("apiVersion must be 1")
+// loadDocuments merges the documents and dispatches them to the loader of their apiVersion.
+func loadDocuments(docs []document) (Config, error) {
+	version := docs[0].header.APIVersion
+	for _, doc := range docs[1:] {
+		if doc.header.APIVersion != version {
+			return Config{}, fmt.Errorf("%s: apiVersion %d differs from apiVersion %d of %s",
+				doc.path, doc.header.APIVersion, version, docs[0].path)
+		}
+	}
+	if err := checkDuplicateNames(docs); err != nil {
+		return Config{}, err
+	}
+
+	switch version {
+	case One:
+		var merged yamlConfig
+		for _, doc := range docs {
+			var yc yamlConfig
+			if err := yaml.Unmarshal(doc.data, &yc); err != nil {
+				return Config{}, doc.wrap(err)
+			}
+			merged.APIVersion = yc.APIVersion
+			merged.Config = append(merged.Config, yc.Config...)
+			merged.yamlSections = merged.yamlSections.merge(yc.yamlSections)
+		}
+		return configFromYamlConfig(merged)
+	case Two:
+		var merged yamlConfigV2
+		var defaultsPath string
+		for _, doc := range docs {
+			var yc yamlConfigV2
+			if err := yaml.Unmarshal(doc.data, &yc); err != nil {
+				return Config{}, doc.wrap(err)
+			}
+			if doc.header.Defaults.Kind != 0 {
+				if defaultsPath != "" {
+					return Config{}, fmt.Errorf("%s: defaults are already set in %s", doc.path, defaultsPath)
+				}
+				defaultsPath = doc.path
+				merged.Defaults = yc.Defaults
+			}
+			merged.APIVersion = yc.APIVersion
+			merged.Providers = append(merged.Providers, yc.Providers...)
+			merged.Items = append(merged.Items, yc.Items...)
+			merged.yamlSections = merged.yamlSections.merge(yc.yamlSections)
+		}
+		return configFromYamlConfigV2(merged)
+	default:
+		return Config{}, errors.New("apiVersion must be 1 or 2")
 	}
-	c := configFromYamlConfig(cfg)
-	return c, nil
 }
 
 type yamlConfig struct {
-	APIVersion APIVersion `yaml:"apiVersion"`
-	Config     []yamlItem `yaml:"config"`
+	APIVersion   APIVersion `yaml:"apiVersion"`
+	Config       []yamlItem `yaml:"config"`
+	yamlSections `yaml:",inline"`
+}
+
+// yamlSections are the top-level sections shared by all API versions.
+type yamlSections struct {
+	HealthChecks []yamlHealthCheck `yaml:"healthChecks"`
+	Zones        []yamlZone        `yaml:"zones"`
+	Records      []yamlRecord      `yaml:"records"`
+	ReverseZones []string          `yaml:"reverseZones"`
+}
+
+func (s yamlSections) merge(other yamlSections) yamlSections {
+	return yamlSections{
+		HealthChecks: append(s.HealthChecks, other.HealthChecks...),
+		Zones:        append(s.Zones, other.Zones...),
+		Records:      append(s.Records, other.Records...),
+		ReverseZones: append(s.ReverseZones, other.ReverseZones...),
+	}
 }
 
 type yamlItem struct {
-	IP      IP        `yaml:"ip"`
-	Domain  string    `yaml:"domain"`
-	Aliases yaml.Node `yaml:"aliases"`
+	IP                   IP               `yaml:"ip"`
+	Alias                *yamlAliasTarget `yaml:"alias"`
+	Domain               string           `yaml:"domain"`
+	Aliases              yaml.Node        `yaml:"aliases"`
+	Flatten              bool             `yaml:"flatten"`
+	MaxDepth             int              `yaml:"maxDepth"`
+	EvaluateTargetHealth *bool            `yaml:"evaluateTargetHealth"`
+	Routing              *yamlRouting     `yaml:"routing"`
 }
 
-func configFromYamlConfig(yamlCfg yamlConfig) Config {
+type yamlAliasTarget struct {
+	Type                 AliasTargetType `yaml:"type"`
+	DNSName              string          `yaml:"dnsName"`
+	Region               string          `yaml:"region"`
+	HostedZoneID         string          `yaml:"hostedZoneId"`
+	EvaluateTargetHealth *bool           `yaml:"evaluateTargetHealth"`
+}
+
+func configFromYamlConfig(yamlCfg yamlConfig) (Config, error) {
 	cfg := Config{
 		APIVersion: yamlCfg.APIVersion,
 	}
 	items := make([]Item, len(yamlCfg.Config))
 	for i, cfgItem := range yamlCfg.Config {
-		item := Item{
-			IP:     cfgItem.IP,
-			Domain: domainOfString(cfgItem.Domain),
+		item, err := itemFromYaml(cfgItem)
+		if err != nil {
+			return Config{}, err
 		}
-		item.Aliases = nodesFromYaml(cfgItem.Aliases)
 		items[i] = item
 	}
 	cfg.Config = items
 
-	return cfg
+	return completeConfig(cfg, yamlCfg.yamlSections)
+}
+
+func itemFromYaml(cfgItem yamlItem) (Item, error) {
+	item := Item{
+		IP:       cfgItem.IP,
+		Domain:   domainOfString(cfgItem.Domain),
+		Flatten:  cfgItem.Flatten,
+		MaxDepth: cfgItem.MaxDepth,
+	}
+	if (cfgItem.IP == "") == (cfgItem.Alias == nil) {
+		return Item{}, fmt.Errorf("item %s: exactly one of ip and alias must be set", item.Domain)
+	}
+	if cfgItem.MaxDepth < 0 {
+		return Item{}, fmt.Errorf("item %s: maxDepth must not be negative", item.Domain)
+	}
+	if cfgItem.Flatten && cfgItem.MaxDepth > 0 {
+		return Item{}, fmt.Errorf("item %s: only one of flatten and maxDepth may be set", item.Domain)
+	}
+	if cfgItem.Alias != nil {
+		target, err := aliasTargetFromYaml(*cfgItem.Alias)
+		if err != nil {
+			return Item{}, fmt.Errorf("item %s: %w", item.Domain, err)
+		}
+		item.Alias = &target
+	}
+	if cfgItem.Routing != nil {
+		routing, err := routingFromYaml(*cfgItem.Routing)
+		if err != nil {
+			return Item{}, fmt.Errorf("item %s: %w", item.Domain, err)
+		}
+		item.Routing = routing
+	}
+	if cfgItem.EvaluateTargetHealth != nil {
+		item.IgnoreTargetHealth = !*cfgItem.EvaluateTargetHealth
+	}
+	item.Aliases = nodesFromYaml(cfgItem.Aliases)
+	if err := validateWildcards(item.Domain, item.Aliases); err != nil {
+		return Item{}, fmt.Errorf("item %s: %w", item.Domain, err)
+	}
+	return item, nil
+}
+
+// completeConfig adds the top-level sections to the items of cfg.
+func completeConfig(cfg Config, sections yamlSections) (Config, error) {
+	healthChecks, err := healthChecksFromYaml(sections.HealthChecks)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.HealthChecks = healthChecks
+	zones, err := zonesFromYaml(sections.Zones)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Zones = zones
+	records, err := recordsFromYaml(sections.Records)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Records = append(cfg.Records, records...)
+	if err := checkDuplicateRecords(cfg.Records); err != nil {
+		return Config{}, err
+	}
+	reverseZones, err := reverseZonesFromYaml(sections.ReverseZones)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.ReverseZones = reverseZones
+
+	if err := checkDuplicateItems(cfg.Config); err != nil {
+		return Config{}, err
+	}
+	for _, item := range cfg.Config {
+		if name := item.Routing.HealthCheck; name != "" && !hasHealthCheck(healthChecks, name) {
+			return Config{}, fmt.Errorf("item %s: unknown health check %q", item.Domain, name)
+		}
+	}
+
+	return cfg, nil
+}
+
+func healthChecksFromYaml(yamlChecks []yamlHealthCheck) ([]HealthCheck, error) {
+	if len(yamlChecks) == 0 {
+		return nil, nil
+	}
+	result := make([]HealthCheck, len(yamlChecks))
+	for i, yh := range yamlChecks {
+		hc, err := healthCheckFromYaml(yh)
+		if err != nil {
+			return nil, err
+		}
+		if hasHealthCheck(result[:i], hc.Name) {
+			return nil, fmt.Errorf("duplicate health check %s", hc.Name)
+		}
+		result[i] = hc
+	}
+	return result, nil
+}
+
+func hasHealthCheck(checks []HealthCheck, name string) bool {
+	for _, hc := range checks {
+		if hc.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasTargetFromYaml converts the alias target and resolves its canonical hosted zone ID.
+// The DNS name is lowercased, the way Route53 lists it.
+func aliasTargetFromYaml(yt yamlAliasTarget) (AliasTarget, error) {
+	target := AliasTarget{
+		Type:                 yt.Type,
+		DNSName:              domainOfString(strings.ToLower(yt.DNSName)),
+		Region:               yt.Region,
+		HostedZoneID:         yt.HostedZoneID,
+		EvaluateTargetHealth: yt.Type != CloudFront,
+	}
+	if yt.DNSName == "" {
+		return AliasTarget{}, errors.New("alias dnsName must be set")
+	}
+	if yt.EvaluateTargetHealth != nil {
+		if *yt.EvaluateTargetHealth && yt.Type == CloudFront {
+			return AliasTarget{}, errors.New("alias evaluateTargetHealth can't be true for a CloudFront distribution")
+		}
+		target.EvaluateTargetHealth = *yt.EvaluateTargetHealth
+	}
+
+	id, err := target.CanonicalHostedZoneID()
+	if err != nil {
+		return AliasTarget{}, err
+	}
+	target.HostedZoneID = id
+	return target, nil
 }
 
 func nodesFromYaml(node yaml.Node) []Node {
+	if node.Kind == 0 {
+		return nil // aliases are omitted
+	}
 	if node.Kind != yaml.SequenceNode {
 		panic("aliases is not a sequence node")
 	}
@@ -108,6 +326,30 @@ func makeLeafNode(value string) Node {
 	}
 }
 
+// validateWildcards checks that the domain and the names of the tree
+// only use * as a whole leftmost label, e.g. *.preview.example.org.
+func validateWildcards(domain Domain, nodes []Node) error {
+	if err := validateWildcard(domain); err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := validateWildcards(n.Value, n.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateWildcard(domain Domain) error {
+	labels := strings.Split(string(domain), ".")
+	for i, label := range labels {
+		if strings.Contains(label, "*") && (i > 0 || label != "*") {
+			return fmt.Errorf("%s: * is only allowed as the leftmost label", domain)
+		}
+	}
+	return nil
+}
+
 func domainOfString(value string) Domain {
 	if strings.HasSuffix(value, ".") {
 		return Domain(value)
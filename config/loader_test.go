@@ -35,6 +35,314 @@ var config1 = []Item{{
 },
 }
 
+const data2 = `apiVersion: 1
+config:
+- domain: app.example.org
+  alias:
+    type: elb
+    dnsName: My-LB-123.eu-west-1.elb.amazonaws.com
+    region: eu-west-1
+  evaluateTargetHealth: false
+  aliases:
+  - www.example.org
+- domain: cdn.example.org
+  alias:
+    type: cloudfront
+    dnsName: d111111abcdef8.cloudfront.net
+- domain: api.example.org
+  alias:
+    type: apigateway
+    dnsName: d-abc123.execute-api.eu-west-1.amazonaws.com
+    region: eu-west-1
+    evaluateTargetHealth: false
+`
+
+var config2 = []Item{{
+	Alias: &AliasTarget{
+		Type:                 ELB,
+		DNSName:              "my-lb-123.eu-west-1.elb.amazonaws.com.",
+		Region:               "eu-west-1",
+		HostedZoneID:         "Z32O12XQLNTSW2",
+		EvaluateTargetHealth: true,
+	},
+	Domain: "app.example.org.",
+	Aliases: []Node{{
+		Value:    "www.example.org.",
+		Children: nil,
+	}},
+	IgnoreTargetHealth: true,
+}, {
+	Alias: &AliasTarget{
+		Type:         CloudFront,
+		DNSName:      "d111111abcdef8.cloudfront.net.",
+		HostedZoneID: "Z2FDTNDATAQYW2",
+	},
+	Domain: "cdn.example.org.",
+}, {
+	Alias: &AliasTarget{
+		Type:         APIGateway,
+		DNSName:      "d-abc123.execute-api.eu-west-1.amazonaws.com.",
+		Region:       "eu-west-1",
+		HostedZoneID: "ZLY8HYME6SFDD",
+	},
+	Domain: "api.example.org.",
+}}
+
+const dataUnknownRegion = `apiVersion: 1
+config:
+- domain: app.example.org
+  alias:
+    type: nlb
+    dnsName: my-nlb.elb.mars-north-1.amazonaws.com
+    region: mars-north-1
+`
+
+const dataCloudFrontTargetHealth = `apiVersion: 1
+config:
+- domain: cdn.example.org
+  alias:
+    type: cloudfront
+    dnsName: d111111abcdef8.cloudfront.net
+    evaluateTargetHealth: true
+`
+
+const dataIPAndAlias = `apiVersion: 1
+config:
+- domain: app.example.org
+  ip: 127.0.0.1
+  alias:
+    type: cloudfront
+    dnsName: d111111abcdef8.cloudfront.net
+`
+
+const dataRouting = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: app.example.org
+  routing:
+    setIdentifier: eu
+    geolocation:
+      continent: EU
+    healthCheckId: 0a1b2c3d
+`
+
+const dataRoutingTwoPolicies = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: app.example.org
+  routing:
+    setIdentifier: blue
+    weight: 10
+    failover: PRIMARY
+`
+
+const dataDuplicateDomain = `apiVersion: 1
+config:
+- ip: 1.1.1.1
+  domain: example.org
+- ip: 2.2.2.2
+  domain: example.org
+`
+
+const dataDuplicateSetIdentifier = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: app.example.org
+  routing:
+    setIdentifier: blue
+    weight: 90
+- ip: 10.0.0.2
+  domain: app.example.org
+  routing:
+    setIdentifier: blue
+    weight: 10
+`
+
+const dataRoutedAndSimpleDomain = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: app.example.org
+  routing:
+    setIdentifier: blue
+    weight: 90
+- ip: 10.0.0.2
+  domain: app.example.org
+`
+
+const dataHealthChecks = `apiVersion: 1
+healthChecks:
+- name: app-blue
+  type: https
+  fqdn: blue.example.org
+  path: /health
+config:
+- ip: 10.0.0.1
+  domain: app.example.org
+  routing:
+    setIdentifier: blue
+    weight: 100
+    healthCheck: app-blue
+`
+
+const dataUnknownHealthCheck = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: app.example.org
+  routing:
+    healthCheck: app-blue
+`
+
+const dataLongHealthCheckName = `apiVersion: 1
+healthChecks:
+- name: app-blue-eu-west-1-production-primary-web
+  type: TCP
+  ip: 10.0.0.1
+  port: 443
+`
+
+const dataZones = `apiVersion: 1
+zones:
+- name: dev.example.org
+  comment: development environment
+- name: internal.example.org
+  vpc:
+    id: vpc-0a1b2c3d
+    region: eu-west-1
+config:
+- ip: 10.0.0.1
+  domain: app.dev.example.org
+`
+
+const dataDuplicateZones = `apiVersion: 1
+zones:
+- name: dev.example.org
+- name: dev.example.org.
+config:
+- ip: 10.0.0.1
+  domain: app.dev.example.org
+`
+
+const dataMaxDepth = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: example.org
+  maxDepth: 2
+  aliases:
+  - www.example.org
+- ip: 10.0.0.2
+  domain: example.com
+  flatten: true
+  aliases:
+  - www.example.com
+`
+
+const dataFlattenAndMaxDepth = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: example.org
+  flatten: true
+  maxDepth: 2
+`
+
+const dataRecords = `apiVersion: 1
+records:
+- name: example.org
+  type: TXT
+  ttl: 3600
+  values:
+  - v=spf1 include:_spf.google.com ~all
+  - google-site-verification=abc123
+- name: example.org
+  type: mx
+  values:
+  - 10 mail.example.org
+  - 20 backup.example.org.
+- name: example.org
+  type: CAA
+  values:
+  - 0 issue letsencrypt.org
+  - 0 iodef "mailto:security@example.org"
+`
+
+const dataDuplicateRecords = `apiVersion: 1
+records:
+- name: example.org
+  type: TXT
+  values:
+  - foo
+- name: example.org.
+  type: TXT
+  values:
+  - bar
+`
+
+const dataInvalidMX = `apiVersion: 1
+records:
+- name: example.org
+  type: MX
+  values:
+  - mail.example.org
+`
+
+const dataSRV = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: example.org
+  aliases:
+  - &api api.example.org
+records:
+- name: _http._tcp.example.org
+  type: SRV
+  values:
+  - priority: 10
+    weight: 5
+    port: 8080
+    target: *api
+  - 20 0 8080 backup.example.org
+`
+
+const dataInvalidSRVPort = `apiVersion: 1
+records:
+- name: _http._tcp.example.org
+  type: SRV
+  values:
+  - priority: 10
+    weight: 5
+    port: 80800
+    target: api.example.org
+`
+
+const dataWildcard = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: preview.example.org
+  aliases:
+  - "*.preview.example.org"
+`
+
+const dataWildcardInTheMiddle = `apiVersion: 1
+config:
+- ip: 10.0.0.1
+  domain: preview.example.org
+  aliases:
+  - www.example.org:
+    - "api.*.example.org"
+`
+
+const dataReverseZones = `apiVersion: 1
+reverseZones:
+- 0.0.10.in-addr.arpa
+- 8.b.d.0.1.0.0.2.ip6.arpa.
+config:
+- ip: 10.0.0.1
+  domain: example.org
+`
+
+const dataForwardReverseZone = `apiVersion: 1
+reverseZones:
+- example.org
+`
+
 func TestLoadFromString(t *testing.T) {
 	type args struct {
 		data string
@@ -54,6 +362,266 @@ func TestLoadFromString(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "alias targets",
+			args: args{data: data2},
+			want: Config{
+				APIVersion: 1,
+				Config:     config2,
+			},
+			wantErr: false,
+		},
+		{
+			name: "routing",
+			args: args{data: dataRouting},
+			want: Config{
+				APIVersion: 1,
+				Config: []Item{{
+					IP:     "10.0.0.1",
+					Domain: "app.example.org.",
+					Routing: Routing{
+						Policy:        GeolocationRouting,
+						SetIdentifier: "eu",
+						Geo:           GeoLocation{ContinentCode: "EU"},
+						HealthCheckID: "0a1b2c3d",
+					},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "health checks",
+			args: args{data: dataHealthChecks},
+			want: Config{
+				APIVersion: 1,
+				Config: []Item{{
+					IP:     "10.0.0.1",
+					Domain: "app.example.org.",
+					Routing: Routing{
+						Policy:        WeightedRouting,
+						SetIdentifier: "blue",
+						Weight:        100,
+						HealthCheck:   "app-blue",
+					},
+				}},
+				HealthChecks: []HealthCheck{{
+					Name:             "app-blue",
+					Type:             HTTPS,
+					FQDN:             "blue.example.org.",
+					Port:             443,
+					Path:             "/health",
+					Interval:         30,
+					FailureThreshold: 3,
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "zones",
+			args: args{data: dataZones},
+			want: Config{
+				APIVersion: 1,
+				Config: []Item{{
+					IP:     "10.0.0.1",
+					Domain: "app.dev.example.org.",
+				}},
+				Zones: []Zone{{
+					Name:    "dev.example.org.",
+					Comment: "development environment",
+				}, {
+					Name:      "internal.example.org.",
+					Private:   true,
+					VPCID:     "vpc-0a1b2c3d",
+					VPCRegion: "eu-west-1",
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "max depth",
+			args: args{data: dataMaxDepth},
+			want: Config{
+				APIVersion: 1,
+				Config: []Item{{
+					IP:       "10.0.0.1",
+					Domain:   "example.org.",
+					Aliases:  []Node{{Value: "www.example.org.", Children: nil}},
+					MaxDepth: 2,
+				}, {
+					IP:      "10.0.0.2",
+					Domain:  "example.com.",
+					Aliases: []Node{{Value: "www.example.com.", Children: nil}},
+					Flatten: true,
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "flatten and max depth",
+			args:    args{data: dataFlattenAndMaxDepth},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "records",
+			args: args{data: dataRecords},
+			want: Config{
+				APIVersion: 1,
+				Config:     []Item{},
+				Records: []Record{{
+					Name:   "example.org.",
+					Type:   TXT,
+					TTL:    3600,
+					Values: []string{"v=spf1 include:_spf.google.com ~all", "google-site-verification=abc123"},
+				}, {
+					Name:   "example.org.",
+					Type:   MX,
+					TTL:    300,
+					Values: []string{"10 mail.example.org.", "20 backup.example.org."},
+				}, {
+					Name:   "example.org.",
+					Type:   CAA,
+					TTL:    300,
+					Values: []string{`0 issue "letsencrypt.org"`, `0 iodef "mailto:security@example.org"`},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "SRV records",
+			args: args{data: dataSRV},
+			want: Config{
+				APIVersion: 1,
+				Config: []Item{{
+					IP:      "10.0.0.1",
+					Domain:  "example.org.",
+					Aliases: []Node{{Value: "api.example.org.", Children: nil}},
+				}},
+				Records: []Record{{
+					Name:   "_http._tcp.example.org.",
+					Type:   SRV,
+					TTL:    300,
+					Values: []string{"10 5 8080 api.example.org.", "20 0 8080 backup.example.org."},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "SRV port out of range",
+			args:    args{data: dataInvalidSRVPort},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "wildcard",
+			args: args{data: dataWildcard},
+			want: Config{
+				APIVersion: 1,
+				Config: []Item{{
+					IP:      "10.0.0.1",
+					Domain:  "preview.example.org.",
+					Aliases: []Node{{Value: "*.preview.example.org.", Children: nil}},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "wildcard that is not the leftmost label",
+			args:    args{data: dataWildcardInTheMiddle},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "reverse zones",
+			args: args{data: dataReverseZones},
+			want: Config{
+				APIVersion: 1,
+				Config: []Item{{
+					IP:     "10.0.0.1",
+					Domain: "example.org.",
+				}},
+				ReverseZones: []Domain{"0.0.10.in-addr.arpa.", "8.b.d.0.1.0.0.2.ip6.arpa."},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "reverse zone outside of in-addr.arpa and ip6.arpa",
+			args:    args{data: dataForwardReverseZone},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate records",
+			args:    args{data: dataDuplicateRecords},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "MX without preference",
+			args:    args{data: dataInvalidMX},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate zones",
+			args:    args{data: dataDuplicateZones},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown health check",
+			args:    args{data: dataUnknownHealthCheck},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "health check name too long",
+			args:    args{data: dataLongHealthCheckName},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "routing with two policies",
+			args:    args{data: dataRoutingTwoPolicies},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate domain",
+			args:    args{data: dataDuplicateDomain},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate set identifier",
+			args:    args{data: dataDuplicateSetIdentifier},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "routed and simple items of a domain",
+			args:    args{data: dataRoutedAndSimpleDomain},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "alias target in unknown region",
+			args:    args{data: dataUnknownRegion},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "cloudfront alias evaluating target health",
+			args:    args{data: dataCloudFrontTargetHealth},
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name:    "both ip and alias",
+			args:    args{data: dataIPAndAlias},
+			want:    Config{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
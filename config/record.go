@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecordType is the DNS type of a Record.
+type RecordType string
+
+// Available Record Types
+const (
+	TXT RecordType = "TXT"
+	MX  RecordType = "MX"
+	CAA RecordType = "CAA"
+	SRV RecordType = "SRV"
+)
+
+// defaultRecordTTL is the TTL of the records that don't set one.
+const defaultRecordTTL = 300 // 5 minutes
+
+// Record is a record set outside of the alias trees, e.g. the TXT records of a name.
+type Record struct {
+	Name Domain
+	Type RecordType
+	// TTL in seconds.
+	TTL int64
+	// Values in zone file notation: the text of a TXT record without quotes,
+	// "<preference> <exchange>" for MX, `<flags> <tag> "<value>"` for CAA
+	// and "<priority> <weight> <port> <target>" for SRV.
+	Values []string
+}
+
+type yamlRecord struct {
+	Name   string            `yaml:"name"`
+	Type   RecordType        `yaml:"type"`
+	TTL    *int64            `yaml:"ttl"`
+	Values []yamlRecordValue `yaml:"values"`
+}
+
+// yamlRecordValue is a value in zone file notation.
+// SRV values may also be a mapping, so that the target can refer to a node
+// of an alias tree with a YAML alias and follows its renames.
+type yamlRecordValue string
+
+type yamlSRV struct {
+	Priority uint16 `yaml:"priority"`
+	Weight   uint16 `yaml:"weight"`
+	Port     uint16 `yaml:"port"`
+	Target   string `yaml:"target"`
+}
+
+func (v *yamlRecordValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		var value string
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		*v = yamlRecordValue(value)
+		return nil
+	}
+
+	var srv yamlSRV
+	if err := node.Decode(&srv); err != nil {
+		return err
+	}
+	if srv.Target == "" {
+		return fmt.Errorf("line %d: SRV value requires target", node.Line)
+	}
+	*v = yamlRecordValue(fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, srv.Target))
+	return nil
+}
+
+func recordsFromYaml(yamlRecords []yamlRecord) ([]Record, error) {
+	if len(yamlRecords) == 0 {
+		return nil, nil
+	}
+	result := make([]Record, len(yamlRecords))
+	for i, yr := range yamlRecords {
+		record, err := recordFromYaml(yr)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = record
+	}
+	return result, nil
+}
+
+// checkDuplicateRecords checks that there is only one record set per name and type.
+func checkDuplicateRecords(records []Record) error {
+	type key struct {
+		name Domain
+		typ  RecordType
+	}
+	seen := make(map[key]bool, len(records))
+	for _, record := range records {
+		k := key{name: record.Name, typ: record.Type}
+		if seen[k] {
+			return fmt.Errorf("duplicate %s record %s", record.Type, record.Name)
+		}
+		seen[k] = true
+	}
+	return nil
+}
+
+func recordFromYaml(yr yamlRecord) (Record, error) {
+	if yr.Name == "" {
+		return Record{}, fmt.Errorf("record name must be set")
+	}
+	record := Record{
+		Name: domainOfString(yr.Name),
+		Type: RecordType(strings.ToUpper(string(yr.Type))),
+		TTL:  defaultRecordTTL,
+	}
+	if err := validateWildcard(record.Name); err != nil {
+		return Record{}, fmt.Errorf("%s record %w", record.Type, err)
+	}
+	if yr.TTL != nil {
+		if *yr.TTL < 0 {
+			return Record{}, fmt.Errorf("%s record %s: ttl must not be negative", record.Type, record.Name)
+		}
+		record.TTL = *yr.TTL
+	}
+	if len(yr.Values) == 0 {
+		return Record{}, fmt.Errorf("%s record %s: values must be set", record.Type, record.Name)
+	}
+
+	normalize, ok := recordValueNormalizers[record.Type]
+	if !ok {
+		return Record{}, fmt.Errorf("record %s: unknown type %q", record.Name, yr.Type)
+	}
+	seen := make(map[string]bool, len(yr.Values))
+	record.Values = make([]string, len(yr.Values))
+	for i, value := range yr.Values {
+		normalized, err := normalize(strings.TrimSpace(string(value)))
+		if err != nil {
+			return Record{}, fmt.Errorf("%s record %s: %w", record.Type, record.Name, err)
+		}
+		if seen[normalized] {
+			return Record{}, fmt.Errorf("%s record %s: duplicate value %q", record.Type, record.Name, value)
+		}
+		seen[normalized] = true
+		record.Values[i] = normalized
+	}
+	return record, nil
+}
+
+// recordValueNormalizers check the values of every Record Type and bring them into
+// the form DNS providers return them in, so that they compare equal.
+var recordValueNormalizers = map[RecordType]func(string) (string, error){
+	TXT: func(value string) (string, error) {
+		return value, nil
+	},
+	MX: func(value string) (string, error) {
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return "", fmt.Errorf("value %q must be \"<preference> <exchange>\"", value)
+		}
+		if _, err := strconv.ParseUint(fields[0], 10, 16); err != nil {
+			return "", fmt.Errorf("value %q: invalid preference %q", value, fields[0])
+		}
+		return fields[0] + " " + string(domainOfString(fields[1])), nil
+	},
+	CAA: func(value string) (string, error) {
+		fields := strings.SplitN(value, " ", 3)
+		if len(fields) != 3 {
+			return "", fmt.Errorf("value %q must be \"<flags> <tag> <value>\"", value)
+		}
+		if _, err := strconv.ParseUint(fields[0], 10, 8); err != nil {
+			return "", fmt.Errorf("value %q: invalid flags %q", value, fields[0])
+		}
+		switch fields[1] {
+		case "issue", "issuewild", "iodef":
+		default:
+			return "", fmt.Errorf("value %q: unknown tag %q", value, fields[1])
+		}
+		if !strings.HasPrefix(fields[2], `"`) {
+			fields[2] = strconv.Quote(fields[2])
+		}
+		return strings.Join(fields, " "), nil
+	},
+	SRV: func(value string) (string, error) {
+		fields := strings.Fields(value)
+		if len(fields) != 4 {
+			return "", fmt.Errorf("value %q must be \"<priority> <weight> <port> <target>\"", value)
+		}
+		for i, name := range []string{"priority", "weight", "port"} {
+			if _, err := strconv.ParseUint(fields[i], 10, 16); err != nil {
+				return "", fmt.Errorf("value %q: invalid %s %q", value, name, fields[i])
+			}
+		}
+		return strings.Join(fields[:3], " ") + " " + string(domainOfString(fields[3])), nil
+	},
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	ipv4ReverseZone Domain = "in-addr.arpa."
+	ipv6ReverseZone Domain = "ip6.arpa."
+)
+
+// ReverseName returns the name of the PTR record of the IP,
+// in in-addr.arpa for IPv4 and in ip6.arpa for IPv6 addresses.
+func (ip IP) ReverseName() (Domain, bool) {
+	parsed := net.ParseIP(string(ip))
+	if parsed == nil {
+		return "", false
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		labels := make([]string, 0, net.IPv4len+1)
+		for i := len(v4) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(v4[i])))
+		}
+		return Domain(strings.Join(append(labels, string(ipv4ReverseZone)), ".")), true
+	}
+
+	const hex = "0123456789abcdef"
+	labels := make([]string, 0, 2*net.IPv6len+1)
+	for i := len(parsed) - 1; i >= 0; i-- {
+		labels = append(labels, string(hex[parsed[i]&0xf]), string(hex[parsed[i]>>4]))
+	}
+	return Domain(strings.Join(append(labels, string(ipv6ReverseZone)), ".")), true
+}
+
+func reverseZonesFromYaml(names []string) ([]Domain, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	seen := make(map[Domain]bool, len(names))
+	result := make([]Domain, len(names))
+	for i, name := range names {
+		zone := domainOfString(strings.ToLower(name))
+		if !strings.HasSuffix(string(zone), "."+string(ipv4ReverseZone)) && !strings.HasSuffix(string(zone), "."+string(ipv6ReverseZone)) {
+			return nil, fmt.Errorf("reverse zone %s must be in %s or %s", zone, ipv4ReverseZone, ipv6ReverseZone)
+		}
+		if seen[zone] {
+			return nil, fmt.Errorf("duplicate reverse zone %s", zone)
+		}
+		seen[zone] = true
+		result[i] = zone
+	}
+	return result, nil
+}
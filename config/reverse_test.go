@@ -0,0 +1,21 @@
+package config
+
+import "testing"
+
+func TestIP_ReverseName(t *testing.T) {
+	tests := []struct {
+		ip     IP
+		want   Domain
+		wantOk bool
+	}{
+		{ip: "10.0.0.1", want: "1.0.0.10.in-addr.arpa.", wantOk: true},
+		{ip: "2001:db8::1", want: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", wantOk: true},
+		{ip: "not an ip", want: "", wantOk: false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.ip.ReverseName()
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("IP(%q).ReverseName() = %q, %v, want %q, %v", tt.ip, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
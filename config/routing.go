@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RoutingPolicy is the Route53 routing policy of a record.
+type RoutingPolicy string
+
+// Available Routing Policies
+const (
+	SimpleRouting      RoutingPolicy = ""
+	WeightedRouting    RoutingPolicy = "weighted"
+	LatencyRouting     RoutingPolicy = "latency"
+	FailoverRouting    RoutingPolicy = "failover"
+	GeolocationRouting RoutingPolicy = "geolocation"
+)
+
+// FailoverRole is the role of a record with the failover routing policy.
+type FailoverRole string
+
+// Available Failover Roles
+const (
+	Primary   FailoverRole = "PRIMARY"
+	Secondary FailoverRole = "SECONDARY"
+)
+
+// GeoLocation selects the origin of DNS queries a geolocation record responds to.
+// "*" as CountryCode selects the default location.
+type GeoLocation struct {
+	ContinentCode   string
+	CountryCode     string
+	SubdivisionCode string
+}
+
+// Routing describes how Route53 chooses among the records of the same name.
+// The zero value is the simple routing policy.
+//
+// Records of the same name are told apart by SetIdentifier.
+// Only the fields of the chosen Policy are meaningful.
+type Routing struct {
+	Policy        RoutingPolicy
+	SetIdentifier string
+
+	Weight   int64        // WeightedRouting
+	Region   string       // LatencyRouting
+	Failover FailoverRole // FailoverRouting
+	Geo      GeoLocation  // GeolocationRouting
+
+	// HealthCheckID makes Route53 stop returning the record if the health check fails.
+	HealthCheckID string
+	// HealthCheck is the name of a HealthCheck from the config.
+	// Config.WithHealthCheckIDs replaces it with HealthCheckID.
+	HealthCheck string
+}
+
+type yamlRouting struct {
+	SetIdentifier string           `yaml:"setIdentifier"`
+	Weight        *int64           `yaml:"weight"`
+	Region        string           `yaml:"region"`
+	Failover      FailoverRole     `yaml:"failover"`
+	GeoLocation   *yamlGeoLocation `yaml:"geolocation"`
+	HealthCheckID string           `yaml:"healthCheckId"`
+	HealthCheck   string           `yaml:"healthCheck"`
+}
+
+type yamlGeoLocation struct {
+	Continent   string `yaml:"continent"`
+	Country     string `yaml:"country"`
+	Subdivision string `yaml:"subdivision"`
+}
+
+// routingFromYaml converts the routing and checks that exactly one policy is chosen.
+func routingFromYaml(yr yamlRouting) (Routing, error) {
+	r := Routing{
+		SetIdentifier: yr.SetIdentifier,
+		HealthCheckID: yr.HealthCheckID,
+		HealthCheck:   yr.HealthCheck,
+	}
+	if r.HealthCheckID != "" && r.HealthCheck != "" {
+		return Routing{}, errors.New("routing must set only one of healthCheckId and healthCheck")
+	}
+
+	policies := 0
+	if yr.Weight != nil {
+		policies++
+		r.Policy = WeightedRouting
+		r.Weight = *yr.Weight
+	}
+	if yr.Region != "" {
+		policies++
+		r.Policy = LatencyRouting
+		r.Region = yr.Region
+	}
+	if yr.Failover != "" {
+		policies++
+		r.Policy = FailoverRouting
+		r.Failover = yr.Failover
+		if r.Failover != Primary && r.Failover != Secondary {
+			return Routing{}, fmt.Errorf("failover must be %s or %s", Primary, Secondary)
+		}
+	}
+	if yr.GeoLocation != nil {
+		policies++
+		r.Policy = GeolocationRouting
+		r.Geo = GeoLocation{
+			ContinentCode:   yr.GeoLocation.Continent,
+			CountryCode:     yr.GeoLocation.Country,
+			SubdivisionCode: yr.GeoLocation.Subdivision,
+		}
+	}
+
+	switch {
+	case policies > 1:
+		return Routing{}, errors.New("routing must set only one of weight, region, failover and geolocation")
+	case policies == 1 && r.SetIdentifier == "":
+		return Routing{}, errors.New("routing requires setIdentifier")
+	case policies == 0 && r.SetIdentifier != "":
+		return Routing{}, errors.New("setIdentifier requires one of weight, region, failover and geolocation")
+	}
+	return r, nil
+}
+
+// checkDuplicateItems checks that the items sharing a domain all have a routing policy
+// with a distinct set identifier, so that every record of a name belongs to a single item.
+func checkDuplicateItems(items []Item) error {
+	type key struct {
+		domain        Domain
+		setIdentifier string
+	}
+	seen := make(map[key]bool, len(items))
+	routed := make(map[Domain]bool, len(items))
+	for _, item := range items {
+		k := key{domain: item.Domain, setIdentifier: item.Routing.SetIdentifier}
+		if seen[k] {
+			if k.setIdentifier == "" {
+				return fmt.Errorf("duplicate item %s, items of the same domain need a routing with a setIdentifier", item.Domain)
+			}
+			return fmt.Errorf("duplicate item %s with setIdentifier %s", item.Domain, k.setIdentifier)
+		}
+		if r, ok := routed[item.Domain]; ok && r != (k.setIdentifier != "") {
+			return fmt.Errorf("item %s: items of the same domain must all have a routing or none", item.Domain)
+		}
+		seen[k] = true
+		routed[item.Domain] = k.setIdentifier != ""
+	}
+	return nil
+}
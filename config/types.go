@@ -1,6 +1,6 @@
 package config
 
-// IP represents an IPv4.
+// IP represents an IPv4 or IPv6 address.
 type IP string
 
 // Domain represents a web domain.
@@ -12,19 +12,52 @@ type APIVersion int
 // Supported API Versions.
 const (
 	One APIVersion = 1
+	Two APIVersion = 2
 )
 
 // Config is a structure that contains the API Version and the config Items.
 type Config struct {
-	APIVersion APIVersion
-	Config     []Item
+	APIVersion   APIVersion
+	Config       []Item
+	HealthChecks []HealthCheck
+	Zones        []Zone
+	Records      []Record
+	// Providers are the DNS providers that the items are published with, since apiVersion 2.
+	Providers []Provider
+	// ReverseZones are the in-addr.arpa and ip6.arpa zones
+	// that get the PTR records of the IPs of the items.
+	ReverseZones []Domain
 }
 
 // Item represents a configuration of IP, Domain and Domain's aliases.
+// Instead of an IP, the Domain may alias an AWS resource.
 type Item struct {
 	IP      IP
+	Alias   *AliasTarget
 	Domain  Domain
 	Aliases []Node
+
+	// Flatten gives every node of the tree copies of the Domain records instead of an alias to its parent.
+	Flatten bool
+	// MaxDepth limits the alias chains of the tree: a node that would be more than MaxDepth aliases away
+	// from the Domain records gets copies of them instead, and a chain starts again below it.
+	// Zero means no limit.
+	MaxDepth int
+
+	// TTL of the records of the item that aren't aliases, in seconds.
+	// Zero means the default TTL of the adapter, and that the TTL of the current records is not compared.
+	TTL int64
+	// Provider is the name of the Provider of the item, empty if there is only one.
+	Provider string
+	// Labels are free-form metadata of the item, e.g. the owning team.
+	Labels map[string]string
+
+	// IgnoreTargetHealth disables the health evaluation of the alias tree records.
+	IgnoreTargetHealth bool
+	// Routing is the routing policy of the Domain record.
+	// Several items may share a Domain if all of them have a routing with distinct set identifiers,
+	// which the loader checks.
+	Routing Routing
 }
 
 // Node is a config tree node.
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UpgradeV1 converts a configuration of apiVersion 1 into apiVersion 2.
+// Both configurations load into the same Config, except for the APIVersion,
+// so they produce identical plans. Comments and anchors are kept.
+func UpgradeV1(data []byte) ([]byte, error) {
+	cfg, err := load(data)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.APIVersion != One {
+		return nil, errors.New("apiVersion must be 1")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "apiVersion":
+			value.Value = "2"
+		case "config":
+			key.Value = "items"
+		}
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
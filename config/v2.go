@@ -0,0 +1,171 @@
+package config
+
+import "fmt"
+
+// Provider is a DNS provider that items are published with.
+// dnser doesn't interpret the Options, they are meant for the code that constructs the adapters.
+type Provider struct {
+	Name    string
+	Type    string
+	Options map[string]string
+}
+
+// ItemsOfProvider returns the items published with the named provider.
+// Items without a provider belong to every provider.
+func (c Config) ItemsOfProvider(name string) []Item {
+	result := make([]Item, 0, len(c.Config))
+	for _, item := range c.Config {
+		if item.Provider == "" || item.Provider == name {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// yamlConfigV2 is the schema of apiVersion 2. It is a superset of apiVersion 1,
+// with the items under "items" instead of "config".
+type yamlConfigV2 struct {
+	APIVersion   APIVersion     `yaml:"apiVersion"`
+	Defaults     yamlDefaults   `yaml:"defaults"`
+	Providers    []yamlProvider `yaml:"providers"`
+	Items        []yamlItemV2   `yaml:"items"`
+	yamlSections `yaml:",inline"`
+}
+
+// yamlDefaults apply to every item, and the TTL also to every record, that doesn't set its own.
+type yamlDefaults struct {
+	TTL                  *int64            `yaml:"ttl"`
+	Provider             string            `yaml:"provider"`
+	Labels               map[string]string `yaml:"labels"`
+	EvaluateTargetHealth *bool             `yaml:"evaluateTargetHealth"`
+}
+
+type yamlProvider struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`
+	Options map[string]string `yaml:"options"`
+}
+
+type yamlItemV2 struct {
+	yamlItem `yaml:",inline"`
+	TTL      *int64            `yaml:"ttl"`
+	Provider string            `yaml:"provider"`
+	Labels   map[string]string `yaml:"labels"`
+	// Records are record sets of the domain of the item, like the top-level records without a name.
+	Records []yamlRecord `yaml:"records"`
+}
+
+func configFromYamlConfigV2(yamlCfg yamlConfigV2) (Config, error) {
+	cfg := Config{
+		APIVersion: yamlCfg.APIVersion,
+	}
+	providers, err := providersFromYaml(yamlCfg.Providers)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Providers = providers
+
+	defaults := yamlCfg.Defaults
+	if defaults.Provider != "" && !hasProvider(providers, defaults.Provider) {
+		return Config{}, fmt.Errorf("defaults: unknown provider %q", defaults.Provider)
+	}
+
+	items := make([]Item, len(yamlCfg.Items))
+	records := make([]yamlRecord, 0)
+	for i, yi := range yamlCfg.Items {
+		if yi.EvaluateTargetHealth == nil {
+			yi.EvaluateTargetHealth = defaults.EvaluateTargetHealth
+		}
+		item, err := itemFromYaml(yi.yamlItem)
+		if err != nil {
+			return Config{}, err
+		}
+
+		if yi.TTL != nil && item.Alias != nil {
+			return Config{}, fmt.Errorf("item %s: an alias has no ttl", item.Domain)
+		}
+		ttl := yi.TTL
+		if ttl == nil {
+			ttl = defaults.TTL
+		}
+		if ttl != nil && item.Alias == nil {
+			if *ttl < 0 {
+				return Config{}, fmt.Errorf("item %s: ttl must not be negative", item.Domain)
+			}
+			item.TTL = *ttl
+		}
+
+		item.Provider = yi.Provider
+		if item.Provider == "" {
+			item.Provider = defaults.Provider
+		}
+		if item.Provider != "" && !hasProvider(providers, item.Provider) {
+			return Config{}, fmt.Errorf("item %s: unknown provider %q", item.Domain, item.Provider)
+		}
+		item.Labels = mergeLabels(defaults.Labels, yi.Labels)
+		items[i] = item
+
+		for _, yr := range yi.Records {
+			if yr.Name != "" {
+				return Config{}, fmt.Errorf("item %s: the records of an item have no name", item.Domain)
+			}
+			yr.Name = string(item.Domain)
+			records = append(records, yr)
+		}
+	}
+	cfg.Config = items
+
+	sections := yamlCfg.yamlSections
+	sections.Records = append(records, sections.Records...)
+	for i := range sections.Records {
+		if sections.Records[i].TTL == nil {
+			sections.Records[i].TTL = defaults.TTL
+		}
+	}
+	return completeConfig(cfg, sections)
+}
+
+func providersFromYaml(yamlProviders []yamlProvider) ([]Provider, error) {
+	if len(yamlProviders) == 0 {
+		return nil, nil
+	}
+	result := make([]Provider, len(yamlProviders))
+	for i, yp := range yamlProviders {
+		if yp.Name == "" || yp.Type == "" {
+			return nil, fmt.Errorf("provider %q: name and type must be set", yp.Name)
+		}
+		if hasProvider(result[:i], yp.Name) {
+			return nil, fmt.Errorf("duplicate provider %s", yp.Name)
+		}
+		result[i] = Provider{
+			Name:    yp.Name,
+			Type:    yp.Type,
+			Options: yp.Options,
+		}
+	}
+	return result, nil
+}
+
+func hasProvider(providers []Provider, name string) bool {
+	for _, p := range providers {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeLabels returns the defaults overridden by the labels.
+func mergeLabels(defaults, labels map[string]string) map[string]string {
+	if len(defaults) == 0 && len(labels) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(defaults)+len(labels))
+	for k, v := range defaults {
+		result[k] = v
+	}
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
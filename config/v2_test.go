@@ -0,0 +1,183 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dataV2 = `apiVersion: 2
+defaults:
+  ttl: 120
+  provider: route53
+  labels:
+    team: platform
+providers:
+- name: route53
+  type: route53
+- name: internal
+  type: coredns
+  options:
+    path: /etc/coredns/zones
+items:
+- ip: 10.0.0.1
+  domain: example.org
+  ttl: 60
+  labels:
+    service: web
+  records:
+  - type: TXT
+    values:
+    - v=spf1 -all
+  aliases:
+  - www.example.org
+- ip: 192.168.0.1
+  domain: lan.example.org
+  provider: internal
+records:
+- name: _http._tcp.example.org
+  type: SRV
+  ttl: 300
+  values:
+  - 10 5 8080 www.example.org
+`
+
+const dataV2UnknownProvider = `apiVersion: 2
+items:
+- ip: 10.0.0.1
+  domain: example.org
+  provider: route53
+`
+
+const dataV2AliasTTL = `apiVersion: 2
+items:
+- domain: example.org
+  ttl: 60
+  alias:
+    type: cloudfront
+    dnsName: d111111abcdef8.cloudfront.net
+`
+
+const dataV3 = `apiVersion: 3
+`
+
+func TestLoadFromString_v2(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "defaults, providers and records",
+			data: dataV2,
+			want: Config{
+				APIVersion: Two,
+				Config: []Item{{
+					IP:       "10.0.0.1",
+					Domain:   "example.org.",
+					Aliases:  []Node{{Value: "www.example.org.", Children: nil}},
+					TTL:      60,
+					Provider: "route53",
+					Labels:   map[string]string{"team": "platform", "service": "web"},
+				}, {
+					IP:       "192.168.0.1",
+					Domain:   "lan.example.org.",
+					TTL:      120,
+					Provider: "internal",
+					Labels:   map[string]string{"team": "platform"},
+				}},
+				Records: []Record{{
+					Name:   "example.org.",
+					Type:   TXT,
+					TTL:    120,
+					Values: []string{"v=spf1 -all"},
+				}, {
+					Name:   "_http._tcp.example.org.",
+					Type:   SRV,
+					TTL:    300,
+					Values: []string{"10 5 8080 www.example.org."},
+				}},
+				Providers: []Provider{{
+					Name: "route53",
+					Type: "route53",
+				}, {
+					Name:    "internal",
+					Type:    "coredns",
+					Options: map[string]string{"path": "/etc/coredns/zones"},
+				}},
+			},
+		},
+		{name: "unknown provider", data: dataV2UnknownProvider, wantErr: true},
+		{name: "ttl of an alias", data: dataV2AliasTTL, wantErr: true},
+		{name: "unknown apiVersion", data: dataV3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadFromString(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFromString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadFromString() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_ItemsOfProvider(t *testing.T) {
+	cfg, err := LoadFromString(dataV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := cfg.ItemsOfProvider("internal")
+	if len(got) != 1 || got[0].Domain != "lan.example.org." {
+		t.Errorf("ItemsOfProvider() = %v, want lan.example.org.", got)
+	}
+}
+
+func TestUpgradeV1(t *testing.T) {
+	tests := map[string]string{
+		"all good":      data1,
+		"alias targets": data2,
+		"routing":       dataRouting,
+		"health checks": dataHealthChecks,
+		"zones":         dataZones,
+		"records":       dataRecords,
+		"SRV anchors":   dataSRV,
+		"max depth":     dataMaxDepth,
+		"wildcard":      dataWildcard,
+		"reverse zones": dataReverseZones,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			v1, err := LoadFromString(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			upgraded, err := UpgradeV1([]byte(data))
+			if err != nil {
+				t.Fatalf("UpgradeV1() error = %v", err)
+			}
+			if !strings.HasPrefix(string(upgraded), "apiVersion: 2\n") {
+				t.Errorf("UpgradeV1() = %s, want apiVersion 2", upgraded)
+			}
+
+			v2, err := LoadFromString(string(upgraded))
+			if err != nil {
+				t.Fatalf("LoadFromString(UpgradeV1()) error = %v", err)
+			}
+			v1.APIVersion = Two
+			if !reflect.DeepEqual(v2, v1) {
+				t.Errorf("LoadFromString(UpgradeV1()) = %v, want %v", v2, v1)
+			}
+		})
+	}
+}
+
+func TestUpgradeV1_v2(t *testing.T) {
+	if _, err := UpgradeV1([]byte(dataV2)); err == nil {
+		t.Error("UpgradeV1() error = nil, want an error for apiVersion 2")
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import "fmt"
+
+// Zone is a hosted zone that dnser creates if it doesn't exist.
+type Zone struct {
+	Name    Domain
+	Comment string
+	// Private zones are only resolvable from the VPC.
+	Private   bool
+	VPCID     string
+	VPCRegion string
+}
+
+type yamlZone struct {
+	Name    string   `yaml:"name"`
+	Comment string   `yaml:"comment"`
+	VPC     *yamlVPC `yaml:"vpc"`
+}
+
+type yamlVPC struct {
+	ID     string `yaml:"id"`
+	Region string `yaml:"region"`
+}
+
+func zonesFromYaml(yamlZones []yamlZone) ([]Zone, error) {
+	if len(yamlZones) == 0 {
+		return nil, nil
+	}
+	seen := make(map[Domain]bool, len(yamlZones))
+	result := make([]Zone, len(yamlZones))
+	for i, yz := range yamlZones {
+		if yz.Name == "" {
+			return nil, fmt.Errorf("zone name must be set")
+		}
+		zone := Zone{
+			Name:    domainOfString(yz.Name),
+			Comment: yz.Comment,
+		}
+		if yz.VPC != nil {
+			if yz.VPC.ID == "" || yz.VPC.Region == "" {
+				return nil, fmt.Errorf("zone %s: vpc requires id and region", zone.Name)
+			}
+			zone.Private = true
+			zone.VPCID = yz.VPC.ID
+			zone.VPCRegion = yz.VPC.Region
+		}
+		if seen[zone.Name] {
+			return nil, fmt.Errorf("duplicate zone %s", zone.Name)
+		}
+		seen[zone.Name] = true
+		result[i] = zone
+	}
+	return result, nil
+}
@@ -7,6 +7,8 @@ require (
 	github.com/aws/aws-sdk-go-v2/config v1.1.3
 	github.com/aws/aws-sdk-go-v2/credentials v1.1.3
 	github.com/aws/aws-sdk-go-v2/service/route53 v1.2.0
+	github.com/aws/aws-sdk-go-v2/service/sts v1.2.0
+	github.com/aws/smithy-go v1.2.0
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
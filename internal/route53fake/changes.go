@@ -0,0 +1,212 @@
+package route53fake
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// applyChanges returns the records of the zone after the changes,
+// or the problems that make Route53 reject the whole batch.
+func (s *Server) applyChanges(z *zone, changes []Change) ([]ResourceRecordSet, []string) {
+	if len(changes) > maxChanges {
+		return nil, []string{fmt.Sprintf("Number of records limit of %d exceeded.", maxChanges)}
+	}
+
+	records := append([]ResourceRecordSet(nil), z.records...)
+	problems := make([]string, 0)
+	seen := make(map[recordKey]bool, len(changes))
+
+	for _, c := range changes {
+		rrset := c.ResourceRecordSet
+		rrset.Name = canonicalName(rrset.Name)
+		key := rrset.key()
+
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf(
+				"The request contains an invalid set of changes for a resource record set '%s %s'", rrset.Type, rrset.Name))
+			continue
+		}
+		seen[key] = true
+
+		if problem := z.validate(rrset); problem != "" {
+			problems = append(problems, problem)
+			continue
+		}
+
+		i := findRecord(records, key)
+		switch c.Action {
+		case "CREATE":
+			if i >= 0 {
+				problems = append(problems, fmt.Sprintf(
+					"Tried to create resource record set [name='%s', type='%s'] but it already exists", rrset.Name, rrset.Type))
+				continue
+			}
+			records = append(records, rrset)
+		case "UPSERT":
+			if i >= 0 {
+				records[i] = rrset
+			} else {
+				records = append(records, rrset)
+			}
+		case "DELETE":
+			if i < 0 {
+				problems = append(problems, fmt.Sprintf(
+					"Tried to delete resource record set [name='%s', type='%s'] but it was not found", rrset.Name, rrset.Type))
+				continue
+			}
+			if !reflect.DeepEqual(records[i], rrset) {
+				problems = append(problems, fmt.Sprintf(
+					"Tried to delete resource record set [name='%s', type='%s'] but the values provided do not match the current values",
+					rrset.Name, rrset.Type))
+				continue
+			}
+			records = append(records[:i], records[i+1:]...)
+		default:
+			problems = append(problems, fmt.Sprintf("Unknown action %q", c.Action))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	for _, c := range changes {
+		if c.Action == "DELETE" || c.ResourceRecordSet.AliasTarget == nil {
+			continue
+		}
+		if problem := s.checkAliasTarget(z, records, c.ResourceRecordSet); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	problems = append(problems, checkConflicts(records)...)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	sortRecords(records)
+	return records, nil
+}
+
+// validate checks a record set on its own.
+func (z *zone) validate(rrset ResourceRecordSet) string {
+	if rrset.Name != z.name && !strings.HasSuffix(rrset.Name, "."+z.name) {
+		return fmt.Sprintf("RRSet with DNS name %s is not permitted in zone %s", rrset.Name, z.name)
+	}
+	if (rrset.AliasTarget == nil) == (len(rrset.ResourceRecords) == 0) {
+		return fmt.Sprintf("RRSet %s must have either resource records or an alias target", rrset.Name)
+	}
+	if rrset.AliasTarget != nil && rrset.TTL != nil {
+		return fmt.Sprintf("RRSet %s: an alias must not have a TTL", rrset.Name)
+	}
+	if rrset.AliasTarget == nil && rrset.TTL == nil {
+		return fmt.Sprintf("RRSet %s: a TTL is required", rrset.Name)
+	}
+	if rrset.Type == "CNAME" && rrset.Name == z.name {
+		return fmt.Sprintf("RRSet of type CNAME with DNS name %s is not permitted at apex in zone %s", rrset.Name, z.name)
+	}
+	if rrset.Type == "A" {
+		for _, r := range rrset.ResourceRecords {
+			if ip := net.ParseIP(r.Value); ip == nil || ip.To4() == nil {
+				return fmt.Sprintf("Invalid Resource Record: FATAL problem: ARRDATAIllegalIPv4Address (Value is not a valid IPv4 address) encountered with '%s'", r.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// checkAliasTarget checks that an alias to a zone of the server targets an existing record.
+// Targets in other zones, e.g. of AWS resources, are not checked.
+func (s *Server) checkAliasTarget(z *zone, records []ResourceRecordSet, rrset ResourceRecordSet) string {
+	target := rrset.AliasTarget
+	targetZone := s.zone(strings.TrimPrefix(target.HostedZoneID, "/hostedzone/"))
+	if targetZone == nil {
+		return ""
+	}
+	if targetZone != z {
+		records = targetZone.records
+	}
+
+	name := canonicalName(target.DNSName)
+	if name != targetZone.name && !strings.HasSuffix(name, "."+targetZone.name) {
+		return fmt.Sprintf("Tried to create an alias that targets %s, type %s in zone %s, but the alias target name does not lie within the target zone",
+			name, rrset.Type, targetZone.id)
+	}
+	for _, r := range records {
+		if r.Name == name && r.Type == rrset.Type {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Tried to create an alias that targets %s, type %s in zone %s, but that target was not found",
+		name, rrset.Type, targetZone.id)
+}
+
+// checkConflicts checks that records of the same name and type either all have a routing policy or none has,
+// and that a CNAME is the only type of its name.
+func checkConflicts(records []ResourceRecordSet) []string {
+	problems := make([]string, 0)
+	routed := make(map[[2]string]bool)
+	types := make(map[string]string)
+	for _, r := range records {
+		if other, ok := types[r.Name]; ok && other != r.Type && (other == "CNAME" || r.Type == "CNAME") {
+			problems = append(problems, fmt.Sprintf(
+				"RRSet of type %s with DNS name %s is not permitted because a conflicting RRSet of type %s with the same DNS name already exists",
+				r.Type, r.Name, other))
+		}
+		types[r.Name] = r.Type
+
+		key := [2]string{r.Name, r.Type}
+		isRouted := r.SetIdentifier != ""
+		if was, ok := routed[key]; ok && was != isRouted {
+			problems = append(problems, fmt.Sprintf(
+				"RRSet of type %s with DNS name %s is not permitted because a conflicting RRSet of type %s with the same DNS name already exists",
+				r.Type, r.Name, r.Type))
+		}
+		routed[key] = isRouted
+	}
+	return problems
+}
+
+type recordKey struct {
+	name, typ, setIdentifier string
+}
+
+func (r ResourceRecordSet) key() recordKey {
+	return recordKey{name: r.Name, typ: r.Type, setIdentifier: r.SetIdentifier}
+}
+
+func findRecord(records []ResourceRecordSet, key recordKey) int {
+	for i, r := range records {
+		if r.key() == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortRecords sorts the records like Route53 lists them:
+// by name with the labels reversed, then by type and set identifier.
+func sortRecords(records []ResourceRecordSet) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return recordLess(records[i], records[j])
+	})
+}
+
+func recordLess(a, b ResourceRecordSet) bool {
+	if an, bn := reversedName(a.Name), reversedName(b.Name); an != bn {
+		return an < bn
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.SetIdentifier < b.SetIdentifier
+}
+
+func reversedName(name string) string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
@@ -0,0 +1,74 @@
+package route53fake
+
+import (
+	"testing"
+)
+
+func aRecord(name, ip string) ResourceRecordSet {
+	return ResourceRecordSet{Name: name, Type: "A", TTL: int64Ptr(300), ResourceRecords: []ResourceRecord{{Value: ip}}}
+}
+
+func TestServer_applyChanges(t *testing.T) {
+	s := &Server{changes: make(map[string]*change)}
+	z := s.addZone("example.org.", "", "", false)
+	z.records = append(z.records, aRecord("example.org.", "127.0.0.1"))
+	sortRecords(z.records)
+
+	alias := ResourceRecordSet{Name: "www.example.org.", Type: "A", AliasTarget: &AliasTarget{HostedZoneID: z.id, DNSName: "api.example.org."}}
+	weighted := aRecord("example.org.", "127.0.0.2")
+	weighted.SetIdentifier, weighted.Weight = "blue", int64Ptr(1)
+
+	tests := []struct {
+		name    string
+		changes []Change
+		wantErr bool
+	}{
+		{name: "upsert", changes: []Change{{Action: "UPSERT", ResourceRecordSet: aRecord("example.org.", "127.0.0.2")}}},
+		{name: "create existing", changes: []Change{{Action: "CREATE", ResourceRecordSet: aRecord("example.org.", "127.0.0.2")}}, wantErr: true},
+		{name: "delete", changes: []Change{{Action: "DELETE", ResourceRecordSet: aRecord("example.org", "127.0.0.1")}}},
+		{name: "delete other values", changes: []Change{{Action: "DELETE", ResourceRecordSet: aRecord("example.org.", "127.0.0.2")}}, wantErr: true},
+		{name: "delete missing", changes: []Change{{Action: "DELETE", ResourceRecordSet: aRecord("api.example.org.", "127.0.0.1")}}, wantErr: true},
+		{name: "outside of the zone", changes: []Change{{Action: "UPSERT", ResourceRecordSet: aRecord("example.com.", "127.0.0.1")}}, wantErr: true},
+		{name: "invalid IP", changes: []Change{{Action: "UPSERT", ResourceRecordSet: aRecord("api.example.org.", "::1")}}, wantErr: true},
+		{name: "alias to a missing target", changes: []Change{{Action: "UPSERT", ResourceRecordSet: alias}}, wantErr: true},
+		{name: "alias to a target of the same batch", changes: []Change{
+			{Action: "UPSERT", ResourceRecordSet: alias},
+			{Action: "UPSERT", ResourceRecordSet: aRecord("api.example.org.", "127.0.0.1")},
+		}},
+		{name: "same record twice", changes: []Change{
+			{Action: "UPSERT", ResourceRecordSet: aRecord("api.example.org.", "127.0.0.1")},
+			{Action: "UPSERT", ResourceRecordSet: aRecord("api.example.org.", "127.0.0.2")},
+		}, wantErr: true},
+		{name: "simple and weighted records of a name", changes: []Change{{Action: "UPSERT", ResourceRecordSet: weighted}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(z.records)
+			_, problems := s.applyChanges(z, tt.changes)
+			if (len(problems) > 0) != tt.wantErr {
+				t.Errorf("applyChanges() problems = %v, wantErr %v", problems, tt.wantErr)
+			}
+			if len(z.records) != before {
+				t.Errorf("applyChanges() modified the zone")
+			}
+		})
+	}
+}
+
+func TestRecordLess(t *testing.T) {
+	records := []ResourceRecordSet{
+		{Name: "www.example.org.", Type: "A"},
+		{Name: "example.org.", Type: "SOA"},
+		{Name: "a.www.example.org.", Type: "A"},
+		{Name: "example.org.", Type: "A", SetIdentifier: "b"},
+		{Name: "example.org.", Type: "A", SetIdentifier: "a"},
+	}
+	sortRecords(records)
+
+	want := []string{"example.org. A a", "example.org. A b", "example.org. SOA ", "www.example.org. A ", "a.www.example.org. A "}
+	for i, r := range records {
+		if got := r.Name + " " + r.Type + " " + r.SetIdentifier; got != want[i] {
+			t.Errorf("records[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+}
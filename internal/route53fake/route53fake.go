@@ -0,0 +1,529 @@
+// Package route53fake is an in-memory stand-in for the Route53 API,
+// so the Route53 adapter can be tested without AWS.
+//
+// It implements the REST-XML protocol of the hosted zone, record set, tag and change operations
+// and validates change batches the way Route53 does for the cases dnser runs into.
+// Point the adapter to it with adapter.WithEndpoint(server.URL).
+package route53fake
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiPrefix = "/2013-04-01/"
+
+	defaultPageSize = 300
+	maxChanges      = 1000
+)
+
+// Server is a fake Route53 API.
+type Server struct {
+	*httptest.Server
+
+	// PageSize is the default number of items of a list page. Defaults to 300 like Route53.
+	PageSize int
+	// PendingPolls is the number of GetChange calls that report a change
+	// as PENDING before it becomes INSYNC.
+	PendingPolls int
+
+	mu       sync.Mutex
+	ids      int
+	throttle int
+	requests int
+	zones    []*zone
+	changes  map[string]*change
+	batches  []Batch
+}
+
+type zone struct {
+	id              string
+	name            string
+	callerReference string
+	comment         string
+	private         bool
+	nameServers     []string
+	records         []ResourceRecordSet
+	tags            map[string]string
+}
+
+type change struct {
+	id        string
+	submitted time.Time
+	polls     int
+}
+
+// Batch is a change batch the server accepted.
+type Batch struct {
+	ZoneID  string
+	Changes []Change
+}
+
+// NewServer starts a Server. Close it when done.
+func NewServer() *Server {
+	s := &Server{changes: make(map[string]*change)}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// AddZone creates a hosted zone with the NS and SOA records Route53 creates, and returns its ID.
+func (s *Server) AddZone(name string, private bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addZone(canonicalName(name), "", "", private).id
+}
+
+// AddRecordSets adds record sets to the zone without validating them.
+func (s *Server) AddRecordSets(zoneID string, records ...ResourceRecordSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z := s.zone(zoneID)
+	for _, r := range records {
+		r.Name = canonicalName(r.Name)
+		z.records = append(z.records, r)
+	}
+	sortRecords(z.records)
+}
+
+// RecordSets returns the record sets of the zone in the order Route53 lists them.
+func (s *Server) RecordSets(zoneID string) []ResourceRecordSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ResourceRecordSet(nil), s.zone(zoneID).records...)
+}
+
+// ZoneID returns the ID of the zone with the name, or "" if there is none.
+func (s *Server) ZoneID(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, z := range s.zones {
+		if z.name == canonicalName(name) {
+			return z.id
+		}
+	}
+	return ""
+}
+
+// SetTags adds tags to the zone.
+func (s *Server) SetTags(zoneID string, tags map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z := s.zone(zoneID)
+	for k, v := range tags {
+		z.tags[k] = v
+	}
+}
+
+// Tags returns the tags of the zone.
+func (s *Server) Tags(zoneID string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags := make(map[string]string)
+	for k, v := range s.zone(zoneID).tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// ThrottleNext makes the next n requests fail with a Throttling error.
+func (s *Server) ThrottleNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttle = n
+}
+
+// Requests returns the number of requests the server received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Batches returns the change batches the server accepted, oldest first.
+func (s *Server) Batches() []Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Batch(nil), s.batches...)
+}
+
+func (s *Server) zone(id string) *zone {
+	for _, z := range s.zones {
+		if z.id == id {
+			return z
+		}
+	}
+	return nil
+}
+
+func (s *Server) addZone(name, callerReference, comment string, private bool) *zone {
+	s.ids++
+	z := &zone{
+		id:              fmt.Sprintf("Z%06d", s.ids),
+		name:            name,
+		callerReference: callerReference,
+		comment:         comment,
+		private:         private,
+		tags:            make(map[string]string),
+	}
+	for i := 1; i <= 4; i++ {
+		z.nameServers = append(z.nameServers, fmt.Sprintf("ns-%d-%d.awsdns.example.", s.ids, i))
+	}
+
+	ns := ResourceRecordSet{Name: name, Type: "NS", TTL: int64Ptr(172800)}
+	for _, server := range z.nameServers {
+		ns.ResourceRecords = append(ns.ResourceRecords, ResourceRecord{Value: server})
+	}
+	soa := ResourceRecordSet{Name: name, Type: "SOA", TTL: int64Ptr(900), ResourceRecords: []ResourceRecord{{
+		Value: z.nameServers[0] + " awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400",
+	}}}
+	z.records = []ResourceRecordSet{ns, soa}
+	sortRecords(z.records)
+
+	s.zones = append(s.zones, z)
+	return z
+}
+
+func (s *Server) addChange() *change {
+	s.ids++
+	c := &change{id: fmt.Sprintf("C%06d", s.ids), submitted: time.Now().UTC()}
+	s.changes[c.id] = c
+	return c
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if s.throttle > 0 {
+		s.throttle--
+		writeError(w, http.StatusBadRequest, "Throttling", "Rate exceeded")
+		return
+	}
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "hostedzone" && r.Method == http.MethodGet:
+		s.listHostedZones(w, r)
+	case len(path) == 1 && path[0] == "hostedzone" && r.Method == http.MethodPost:
+		s.createHostedZone(w, r)
+	case len(path) == 2 && path[0] == "hostedzone" && r.Method == http.MethodGet:
+		s.getHostedZone(w, path[1])
+	case len(path) == 3 && path[0] == "hostedzone" && path[2] == "rrset" && r.Method == http.MethodGet:
+		s.listResourceRecordSets(w, r, path[1])
+	case len(path) == 3 && path[0] == "hostedzone" && path[2] == "rrset" && r.Method == http.MethodPost:
+		s.changeResourceRecordSets(w, r, path[1])
+	case len(path) == 2 && path[0] == "tags" && path[1] == "hostedzone" && r.Method == http.MethodPost:
+		s.listTagsForResources(w, r)
+	case len(path) == 3 && path[0] == "tags" && path[1] == "hostedzone" && r.Method == http.MethodPost:
+		s.changeTagsForResource(w, r, path[2])
+	case len(path) == 2 && path[0] == "change" && r.Method == http.MethodGet:
+		s.getChange(w, path[1])
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("%s %s is not supported", r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) listHostedZones(w http.ResponseWriter, r *http.Request) {
+	start := 0
+	if marker := r.URL.Query().Get("marker"); marker != "" {
+		for i, z := range s.zones {
+			if z.id == marker {
+				start = i
+			}
+		}
+	}
+	end, ok := s.pageEnd(w, r, start, len(s.zones))
+	if !ok {
+		return
+	}
+
+	res := listHostedZonesResponse{Marker: r.URL.Query().Get("marker"), MaxItems: end - start}
+	for _, z := range s.zones[start:end] {
+		res.HostedZones = append(res.HostedZones, z.hostedZone())
+	}
+	if end < len(s.zones) {
+		res.IsTruncated = true
+		res.NextMarker = s.zones[end].id
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (s *Server) createHostedZone(w http.ResponseWriter, r *http.Request) {
+	var req createHostedZoneRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+		return
+	}
+	if req.Name == "" || req.CallerReference == "" {
+		writeError(w, http.StatusBadRequest, "InvalidInput", "Name and CallerReference are required")
+		return
+	}
+	for _, z := range s.zones {
+		if z.callerReference == req.CallerReference {
+			writeError(w, http.StatusConflict, "HostedZoneAlreadyExists",
+				fmt.Sprintf("A hosted zone has already been created with the specified caller reference %s", req.CallerReference))
+			return
+		}
+	}
+	private := req.HostedZoneConfig != nil && req.HostedZoneConfig.PrivateZone
+	if private != (req.VPC != nil) {
+		writeError(w, http.StatusBadRequest, "InvalidVPCId", "A private hosted zone requires a VPC, a public one must not have one")
+		return
+	}
+
+	comment := ""
+	if req.HostedZoneConfig != nil {
+		comment = req.HostedZoneConfig.Comment
+	}
+	z := s.addZone(canonicalName(req.Name), req.CallerReference, comment, private)
+	c := s.addChange()
+
+	w.Header().Set("Location", apiPrefix+"hostedzone/"+z.id)
+	writeXML(w, http.StatusCreated, createHostedZoneResponse{
+		HostedZone:    z.hostedZone(),
+		ChangeInfo:    s.changeInfo(c),
+		DelegationSet: z.delegationSet(),
+		VPC:           req.VPC,
+	})
+}
+
+func (s *Server) getHostedZone(w http.ResponseWriter, id string) {
+	z := s.zone(id)
+	if z == nil {
+		writeError(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: "+id)
+		return
+	}
+	res := getHostedZoneResponse{HostedZone: z.hostedZone()}
+	if !z.private {
+		res.DelegationSet = z.delegationSet()
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (s *Server) listResourceRecordSets(w http.ResponseWriter, r *http.Request, id string) {
+	z := s.zone(id)
+	if z == nil {
+		writeError(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: "+id)
+		return
+	}
+
+	query := r.URL.Query()
+	start := 0
+	if name := query.Get("name"); name != "" {
+		from := ResourceRecordSet{Name: canonicalName(name), Type: query.Get("type"), SetIdentifier: query.Get("identifier")}
+		start = sort.Search(len(z.records), func(i int) bool {
+			return !recordLess(z.records[i], from)
+		})
+	}
+	end, ok := s.pageEnd(w, r, start, len(z.records))
+	if !ok {
+		return
+	}
+
+	res := listResourceRecordSetsResponse{
+		ResourceRecordSets: z.records[start:end],
+		MaxItems:           end - start,
+	}
+	if end < len(z.records) {
+		next := z.records[end]
+		res.IsTruncated = true
+		res.NextRecordName = next.Name
+		res.NextRecordType = next.Type
+		res.NextRecordIdentifier = next.SetIdentifier
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (s *Server) changeResourceRecordSets(w http.ResponseWriter, r *http.Request, id string) {
+	z := s.zone(id)
+	if z == nil {
+		writeError(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: "+id)
+		return
+	}
+	var req changeResourceRecordSetsRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+		return
+	}
+	if len(req.ChangeBatch.Changes) == 0 {
+		writeError(w, http.StatusBadRequest, "InvalidInput", "ChangeBatch must contain at least 1 change")
+		return
+	}
+
+	records, problems := s.applyChanges(z, req.ChangeBatch.Changes)
+	if len(problems) > 0 {
+		writeInvalidChangeBatch(w, problems)
+		return
+	}
+	z.records = records
+	s.batches = append(s.batches, Batch{ZoneID: z.id, Changes: req.ChangeBatch.Changes})
+
+	writeXML(w, http.StatusOK, changeResourceRecordSetsResponse{ChangeInfo: s.changeInfo(s.addChange())})
+}
+
+func (s *Server) listTagsForResources(w http.ResponseWriter, r *http.Request) {
+	var req listTagsForResourcesRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+		return
+	}
+	if len(req.ResourceIDs) > 10 {
+		writeError(w, http.StatusBadRequest, "InvalidInput", "ResourceIds must contain at most 10 IDs")
+		return
+	}
+
+	res := listTagsForResourcesResponse{}
+	for _, id := range req.ResourceIDs {
+		z := s.zone(id)
+		if z == nil {
+			writeError(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: "+id)
+			return
+		}
+		set := resourceTagSet{ResourceType: "hostedzone", ResourceID: z.id}
+		for k, v := range z.tags {
+			set.Tags = append(set.Tags, tag{Key: k, Value: v})
+		}
+		sort.Slice(set.Tags, func(i, j int) bool { return set.Tags[i].Key < set.Tags[j].Key })
+		res.ResourceTagSets = append(res.ResourceTagSets, set)
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (s *Server) changeTagsForResource(w http.ResponseWriter, r *http.Request, id string) {
+	z := s.zone(id)
+	if z == nil {
+		writeError(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: "+id)
+		return
+	}
+	var req changeTagsForResourceRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+		return
+	}
+	for _, t := range req.AddTags {
+		z.tags[t.Key] = t.Value
+	}
+	for _, key := range req.RemoveTagKeys {
+		delete(z.tags, key)
+	}
+	writeXML(w, http.StatusOK, changeTagsForResourceResponse{})
+}
+
+func (s *Server) getChange(w http.ResponseWriter, id string) {
+	c, ok := s.changes[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchChange", "A change with the specified change ID does not exist: "+id)
+		return
+	}
+	info := s.changeInfo(c)
+	c.polls++
+	writeXML(w, http.StatusOK, getChangeResponse{ChangeInfo: info})
+}
+
+func (s *Server) changeInfo(c *change) changeInfo {
+	status := "INSYNC"
+	if c.polls < s.PendingPolls {
+		status = "PENDING"
+	}
+	return changeInfo{
+		ID:          "/change/" + c.id,
+		Status:      status,
+		SubmittedAt: c.submitted.Format(time.RFC3339),
+	}
+}
+
+// pageEnd returns the end of the page starting at start,
+// or writes an error and returns false if maxitems is invalid.
+func (s *Server) pageEnd(w http.ResponseWriter, r *http.Request, start, total int) (int, bool) {
+	size := s.PageSize
+	if size == 0 {
+		size = defaultPageSize
+	}
+	if maxItems := r.URL.Query().Get("maxitems"); maxItems != "" {
+		n, err := strconv.Atoi(maxItems)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "InvalidInput", "maxitems must be a positive integer")
+			return 0, false
+		}
+		size = n
+	}
+
+	end := start + size
+	if end > total {
+		end = total
+	}
+	return end, true
+}
+
+func (z *zone) hostedZone() hostedZone {
+	return hostedZone{
+		ID:              "/hostedzone/" + z.id,
+		Name:            z.name,
+		CallerReference: z.callerReference,
+		Config: hostedZoneConfig{
+			Comment:     z.comment,
+			PrivateZone: z.private,
+		},
+		ResourceRecordSetCount: int64(len(z.records)),
+	}
+}
+
+func (z *zone) delegationSet() *delegationSet {
+	return &delegationSet{NameServers: z.nameServers}
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeXML(w, status, errorResponse{
+		Error: apiError{
+			Type:    "Sender",
+			Code:    code,
+			Message: message,
+		},
+		RequestID: "fake",
+	})
+}
+
+// writeInvalidChangeBatch writes the error of an invalid change batch,
+// which unlike the other errors lists every problem.
+func writeInvalidChangeBatch(w http.ResponseWriter, problems []string) {
+	writeXML(w, http.StatusBadRequest, invalidChangeBatch{
+		Messages:  problems,
+		RequestID: "fake",
+	})
+}
+
+// canonicalName lowercases the name and adds the trailing dot, as Route53 does.
+// Route53 returns the wildcard "*" as "\052".
+func canonicalName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return strings.ReplaceAll(name, "*", `\052`)
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,175 @@
+package route53fake
+
+import "encoding/xml"
+
+// ResourceRecordSet is a record set as the Route53 API serializes it.
+type ResourceRecordSet struct {
+	Name            string
+	Type            string
+	SetIdentifier   string           `xml:",omitempty"`
+	Weight          *int64           `xml:",omitempty"`
+	Region          string           `xml:",omitempty"`
+	GeoLocation     *GeoLocation     `xml:",omitempty"`
+	Failover        string           `xml:",omitempty"`
+	TTL             *int64           `xml:",omitempty"`
+	ResourceRecords []ResourceRecord `xml:"ResourceRecords>ResourceRecord"`
+	AliasTarget     *AliasTarget     `xml:",omitempty"`
+	HealthCheckID   string           `xml:"HealthCheckId,omitempty"`
+}
+
+// ResourceRecord is a value of a record set.
+type ResourceRecord struct {
+	Value string
+}
+
+// AliasTarget is the target of an alias record set.
+type AliasTarget struct {
+	HostedZoneID         string `xml:"HostedZoneId"`
+	DNSName              string
+	EvaluateTargetHealth bool
+}
+
+// GeoLocation is the location of a geolocation record set.
+type GeoLocation struct {
+	ContinentCode   string `xml:",omitempty"`
+	CountryCode     string `xml:",omitempty"`
+	SubdivisionCode string `xml:",omitempty"`
+}
+
+// Change is a change of a change batch.
+type Change struct {
+	Action            string
+	ResourceRecordSet ResourceRecordSet
+}
+
+type hostedZone struct {
+	ID                     string `xml:"Id"`
+	Name                   string
+	CallerReference        string
+	Config                 hostedZoneConfig
+	ResourceRecordSetCount int64
+}
+
+type hostedZoneConfig struct {
+	Comment     string `xml:",omitempty"`
+	PrivateZone bool
+}
+
+type delegationSet struct {
+	NameServers []string `xml:"NameServers>NameServer"`
+}
+
+type vpc struct {
+	VPCRegion string
+	VPCID     string `xml:"VPCId"`
+}
+
+type changeInfo struct {
+	ID          string `xml:"Id"`
+	Status      string
+	SubmittedAt string
+}
+
+type listHostedZonesResponse struct {
+	XMLName     xml.Name     `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ListHostedZonesResponse"`
+	HostedZones []hostedZone `xml:"HostedZones>HostedZone"`
+	Marker      string       `xml:",omitempty"`
+	IsTruncated bool
+	NextMarker  string `xml:",omitempty"`
+	MaxItems    int
+}
+
+type createHostedZoneRequest struct {
+	Name             string
+	CallerReference  string
+	HostedZoneConfig *hostedZoneConfig
+	VPC              *vpc
+}
+
+type createHostedZoneResponse struct {
+	XMLName       xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ CreateHostedZoneResponse"`
+	HostedZone    hostedZone
+	ChangeInfo    changeInfo
+	DelegationSet *delegationSet `xml:",omitempty"`
+	VPC           *vpc           `xml:",omitempty"`
+}
+
+type getHostedZoneResponse struct {
+	XMLName       xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ GetHostedZoneResponse"`
+	HostedZone    hostedZone
+	DelegationSet *delegationSet `xml:",omitempty"`
+}
+
+type listResourceRecordSetsResponse struct {
+	XMLName              xml.Name            `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ListResourceRecordSetsResponse"`
+	ResourceRecordSets   []ResourceRecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated          bool
+	NextRecordName       string `xml:",omitempty"`
+	NextRecordType       string `xml:",omitempty"`
+	NextRecordIdentifier string `xml:",omitempty"`
+	MaxItems             int
+}
+
+type changeResourceRecordSetsRequest struct {
+	ChangeBatch struct {
+		Comment string
+		Changes []Change `xml:"Changes>Change"`
+	}
+}
+
+type changeResourceRecordSetsResponse struct {
+	XMLName    xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsResponse"`
+	ChangeInfo changeInfo
+}
+
+type getChangeResponse struct {
+	XMLName    xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ GetChangeResponse"`
+	ChangeInfo changeInfo
+}
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+type resourceTagSet struct {
+	ResourceType string
+	ResourceID   string `xml:"ResourceId"`
+	Tags         []tag  `xml:"Tags>Tag"`
+}
+
+type listTagsForResourcesRequest struct {
+	ResourceIDs []string `xml:"ResourceIds>ResourceId"`
+}
+
+type listTagsForResourcesResponse struct {
+	XMLName         xml.Name         `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ListTagsForResourcesResponse"`
+	ResourceTagSets []resourceTagSet `xml:"ResourceTagSets>ResourceTagSet"`
+}
+
+type changeTagsForResourceRequest struct {
+	AddTags       []tag    `xml:"AddTags>Tag"`
+	RemoveTagKeys []string `xml:"RemoveTagKeys>Key"`
+}
+
+type changeTagsForResourceResponse struct {
+	XMLName xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeTagsForResourceResponse"`
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ErrorResponse"`
+	Error     apiError
+	RequestID string `xml:"RequestId"`
+}
+
+type apiError struct {
+	Type    string
+	Code    string
+	Message string
+}
+
+type invalidChangeBatch struct {
+	XMLName   xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ InvalidChangeBatch"`
+	Messages  []string `xml:"Messages>Message"`
+	RequestID string   `xml:"RequestId"`
+}
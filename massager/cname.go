@@ -0,0 +1,76 @@
+package massager
+
+import (
+	"fmt"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+// useCNAMEs replaces the aliases of every group of desired records with CNAMEs.
+// A CNAME can't be at a zone apex, next to other records of its name, including the other records,
+// or be the target of an MX or SRV record. Such names get copies of the addresses
+// of the root of their tree instead, which Plan finds by the names of the nodes like the copies of maxDepth.
+// The other records are the desired record sets and the current records outside of the trees,
+// e.g. a TXT record dnser doesn't manage.
+func useCNAMEs(groups [][]config.Item, desired [][]dnser.DNSRecord, other, current []dnser.DNSRecord) ([][]dnser.DNSRecord, []Warning) {
+	recordsPerName := make(map[config.Domain]int)
+	for _, records := range desired {
+		for _, r := range records {
+			recordsPerName[r.Name]++
+		}
+	}
+	notCNAME := make(map[config.Domain]bool)
+	for _, r := range append(other[:len(other):len(other)], nonTreeRecords(current)...) {
+		notCNAME[r.Name] = true
+		if target, ok := recordSetTarget(r); ok {
+			notCNAME[target] = true
+		}
+	}
+
+	warnings := make([]Warning, 0)
+	result := make([][]dnser.DNSRecord, len(desired))
+	for i, records := range desired {
+		domain := groups[i][0].Domain
+		addresses := rootAddresses(domain, records)
+
+		result[i] = make([]dnser.DNSRecord, 0, len(records))
+		for _, r := range records {
+			switch {
+			case !r.Alias:
+				result[i] = append(result[i], r)
+			case r.Name != r.NameZone() && recordsPerName[r.Name] == 1 && !notCNAME[r.Name]:
+				result[i] = append(result[i], dnser.DNSRecord{
+					Type:    dnser.TypeCNAME,
+					Name:    r.Name,
+					Target:  r.Target,
+					TTL:     groups[i][0].TTL,
+					Routing: r.Routing,
+				})
+			case len(addresses) > 0:
+				for _, address := range addresses {
+					address.Name = r.Name
+					result[i] = append(result[i], address)
+				}
+			default:
+				warnings = append(warnings, Warning{
+					Message: fmt.Sprintf("%s can't be a CNAME and %s has no addresses to copy", r.Name, domain),
+					Records: []dnser.DNSRecord{r},
+				})
+			}
+		}
+		result[i] = uniqueRecords(result[i])
+	}
+	return result, warnings
+}
+
+// rootAddresses returns the A and AAAA records of the root of a tree.
+func rootAddresses(domain config.Domain, records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0)
+	for _, r := range records {
+		if r.Name == domain && !r.Alias && r.Type != dnser.TypeCNAME {
+			result = append(result, r)
+		}
+	}
+	return result
+}
@@ -1,6 +1,9 @@
 package massager
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/flood4life/dnser"
 	"github.com/flood4life/dnser/config"
 )
@@ -9,70 +12,303 @@ import (
 type Massager struct {
 	Desired []config.Item
 	Current []dnser.DNSRecord
+	// Records are the desired record sets outside of the alias trees, e.g. TXT records.
+	Records []config.Record
+	// ReverseZones opt in to PTR records: the IPs of Desired in these zones get PTR records
+	// to their domains, the other PTR records of the zones are deleted.
+	ReverseZones []config.Domain
+	// PruneRecordSets deletes the current record sets that aren't in Records,
+	// if Records has a set of their type in their zone. Otherwise only the names
+	// and types of Records are managed, so record sets removed from Records are left behind.
+	PruneRecordSets bool
+
+	// Previous are the items of the config the current records were last planned with,
+	// e.g. the config of the previously applied commit. The copies of the root records
+	// that the removed nodes got, with flatten, maxDepth or instead of a CNAME,
+	// are only recognized by their names, so they are deleted only if they are in Previous.
+	// It is required with those options; without it Plan warns about the possible copies.
+	Previous []config.Item
+
+	// BreakCycles deletes the current records that close an alias loop,
+	// unless they are desired. Otherwise the loops are only reported.
+	BreakCycles bool
+	// Strategy is how the adapter represents the edges of the trees, see dnser.AliasStrategyOf.
+	Strategy dnser.AliasStrategy
+}
+
+// Plan is the result of the Massager.
+type Plan struct {
+	// Actions are the groups of actions as returned by CalculateNeededActions.
+	Actions [][]dnser.Action
+	// Warnings are the problems found in the current records that don't stop the plan.
+	Warnings []Warning
+}
+
+// Warning describes a problem found while planning.
+type Warning struct {
+	Message string
+	Records []dnser.DNSRecord
 }
 
 // CalculateNeededActions returns the list of actions necessary to transform
 // the current state to the desired state.
 func (m Massager) CalculateNeededActions() [][]dnser.Action {
+	return m.Plan().Actions
+}
+
+// Plan returns the actions necessary to transform the current state to the desired state
+// together with the warnings about the current state.
+func (m Massager) Plan() Plan {
 	putActions := make([]dnser.DNSRecord, 0)
 	delActions := make([]dnser.DNSRecord, 0)
+	warnings := make([]Warning, 0)
 
-	for _, cfg := range m.Desired {
-		treeCurrent := transformIntoTree(cfg.Domain, m.Current)
-		flatCurrent := flattenTree(cfg.Domain, treeCurrent)
+	current := treeRecords(m.Current)
+	desiredSets := recordSetRecords(m.Records)
 
-		haveARecord := findPresentARecord(cfg.IP, cfg.Domain, m.Current)
-		if haveARecord != nil {
-			flatCurrent = append(flatCurrent, *haveARecord)
+	groups := groupItemsByDomain(m.Desired)
+	desired := make([][]dnser.DNSRecord, len(groups))
+	for i, items := range groups {
+		desired[i] = desiredRecords(items)
+	}
+	if m.Strategy == dnser.CNAMERecords {
+		var cnameWarnings []Warning
+		desired, cnameWarnings = useCNAMEs(groups, desired, desiredSets, m.Current)
+		warnings = append(warnings, cnameWarnings...)
+	}
+	allDesired := make([]dnser.DNSRecord, 0)
+	for _, records := range desired {
+		allDesired = append(allDesired, records...)
+	}
+	warnings = append(warnings, wildcardWarnings(append(allDesired[:len(allDesired):len(allDesired)], desiredSets...))...)
+
+	desiredNames := nodeNames(m.Desired)
+	for i, items := range groups {
+		domain := items[0].Domain
+		flatDesired := desired[i]
+
+		treeCurrent, loops := transformIntoTree(domain, current)
+		flatCurrent := flattenCurrentTree(domain, treeCurrent, current)
+		flatCurrent = append(flatCurrent, findRecordsByName(domain, current)...)
+		// the copies of the current roots at the names of the tree belong to it,
+		// including the names that were removed since the previous config
+		names := nodeNames(append(items[:len(items):len(items)], itemsOfDomain(m.Previous, domain)...))
+		if m.Previous == nil && (m.Strategy == dnser.CNAMERecords || hasCopies(items)) {
+			if w, ok := orphanedCopiesWarning(domain, current, desiredNames); ok {
+				warnings = append(warnings, w)
+			}
 		}
-
-		flatDesired := flattenTree(cfg.Domain, cfg.Aliases)
-		wantARecord := dnser.DNSRecord{
-			Alias:  false,
-			Name:   cfg.Domain,
-			Target: config.Domain(cfg.IP),
+		for _, r := range findCopies(findRecordsByName(domain, current), current, names) {
+			subtree, _ := transformIntoTree(r.Name, current)
+			flatCurrent = append(flatCurrent, r)
+			flatCurrent = append(flatCurrent, flattenCurrentTree(r.Name, subtree, current)...)
 		}
-		flatDesired = append(flatDesired, wantARecord)
+		for _, l := range loops {
+			warnings = append(warnings, l.warning())
+			if m.BreakCycles {
+				flatCurrent = append(flatCurrent, l.record)
+			}
+		}
+		// the desired names may have current records outside of the tree,
+		// e.g. the addresses a name got instead of a CNAME, and subtrees below them
+		for _, name := range recordNames(flatDesired) {
+			if name == domain {
+				continue
+			}
+			flatCurrent = append(flatCurrent, findRecordsByName(name, current)...)
+			subtree, _ := transformIntoTree(name, current)
+			flatCurrent = append(flatCurrent, flattenCurrentTree(name, subtree, current)...)
+		}
+		flatCurrent = uniqueRecords(flatCurrent)
 
 		putActions = append(putActions, findPutActions(flatCurrent, flatDesired)...)
-		delActions = append(delActions, findDeleteActions(flatCurrent, flatDesired)...)
+		delActions = append(delActions, findDeleteActions(flatCurrent, allDesired)...)
 	}
 
 	actions := append(
-		recordsToActions(putActions, dnser.Upsert),
-		recordsToActions(delActions, dnser.Delete)...,
+		recordsToActions(uniqueRecords(putActions), dnser.Upsert),
+		recordsToActions(uniqueRecords(delActions), dnser.Delete)...,
 	)
 
-	return m.splitDependentActions(actions)
+	return Plan{
+		Actions: addIndependentActions(m.splitDependentActions(actions), append(
+			recordSetActions(m.Current, desiredSets, m.PruneRecordSets),
+			ptrActions(m.Current, ptrRecords(m.Desired, m.ReverseZones), m.ReverseZones)...,
+		)),
+		Warnings: warnings,
+	}
+}
+
+// addIndependentActions adds actions that no other action depends on to the first group.
+func addIndependentActions(groups [][]dnser.Action, actions []dnser.Action) [][]dnser.Action {
+	if len(actions) == 0 {
+		return groups
+	}
+	if len(groups) == 0 {
+		return [][]dnser.Action{actions}
+	}
+	groups[0] = append(groups[0], actions...)
+	return groups
+}
+
+// desiredRecords returns the records of the items of a domain.
+func desiredRecords(items []config.Item) []dnser.DNSRecord {
+	domain := items[0].Domain
+	roots := make([]dnser.DNSRecord, len(items))
+	for i, cfg := range items {
+		roots[i] = rootRecord(cfg)
+	}
+
+	records := make([]dnser.DNSRecord, 0)
+	for i, cfg := range items {
+		opts := treeOptions{
+			ignoreTargetHealth: cfg.IgnoreTargetHealth,
+			maxChain:           maxChain(cfg),
+			roots:              roots,
+		}
+		records = append(records, flattenTree(domain, cfg.Aliases, opts, 0)...)
+		records = append(records, roots[i])
+	}
+	return uniqueRecords(records)
+}
+
+// maxChain returns the longest alias chain the item allows, or -1 if there is no limit.
+func maxChain(cfg config.Item) int {
+	switch {
+	case cfg.Flatten:
+		return 0
+	case cfg.MaxDepth > 0:
+		return cfg.MaxDepth
+	default:
+		return -1
+	}
+}
+
+// findCopies returns the records that copy one of the roots under one of the names.
+// A copy is only recognized by its name, other records with the same address aren't dnser's.
+func findCopies(roots, records []dnser.DNSRecord, names map[config.Domain]bool) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0)
+	for _, r := range records {
+		if !names[r.Name] {
+			continue
+		}
+		for _, root := range roots {
+			if r.Name == root.Name {
+				continue
+			}
+			root.Name = r.Name
+			if root == r {
+				result = append(result, r)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// hasCopies returns whether any of the items gives its nodes copies of its root records.
+func hasCopies(items []config.Item) bool {
+	for _, item := range items {
+		if maxChain(item) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// orphanedCopiesWarning warns about the records that copy the root records of domain
+// at names that aren't nodes of the config. Without Previous they can't be told apart
+// from the copies of removed nodes, so they are left alone.
+func orphanedCopiesWarning(domain config.Domain, current []dnser.DNSRecord, names map[config.Domain]bool) (Warning, bool) {
+	others := make(map[config.Domain]bool)
+	for _, r := range current {
+		if !names[r.Name] {
+			others[r.Name] = true
+		}
+	}
+	copies := findCopies(findRecordsByName(domain, current), current, others)
+	if len(copies) == 0 {
+		return Warning{}, false
+	}
+	return Warning{
+		Message: fmt.Sprintf("records outside of the config have the addresses of %s, "+
+			"set Previous to delete them if they are copies of removed nodes", domain),
+		Records: copies,
+	}, true
+}
+
+// itemsOfDomain returns the items of a domain.
+func itemsOfDomain(items []config.Item, domain config.Domain) []config.Item {
+	result := make([]config.Item, 0)
+	for _, item := range items {
+		if item.Domain == domain {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// nodeNames returns the names of the nodes of the items.
+func nodeNames(items []config.Item) map[config.Domain]bool {
+	names := make(map[config.Domain]bool)
+	for _, item := range items {
+		traverseTree(item, func(domain config.Domain, _ int) bool {
+			names[domain] = true
+			return false
+		})
+	}
+	return names
+}
+
+func recordNames(records []dnser.DNSRecord) []config.Domain {
+	seen := make(map[config.Domain]bool, len(records))
+	result := make([]config.Domain, 0, len(records))
+	for _, r := range records {
+		if !seen[r.Name] {
+			seen[r.Name] = true
+			result = append(result, r.Name)
+		}
+	}
+	return result
 }
 
 // splitDependentActions splits the flat list of dnser.Action into several lists.
 // Actions inside a list may be executed concurrently, but the top-level lists
 // need to be executed in the order they are presented, because records in list i+1
 // reference records in list i.
+//
+// Upserts come first, so the surviving records are re-parented before anything is deleted.
+// Then the deletes follow, leaves first, so a record is never deleted while another record
+// still aliases it. Deletes of a name that is also upserted, e.g. when a simple record
+// becomes a weighted one, go together with the upserts, so the provider applies them at once.
 func (m Massager) splitDependentActions(actions []dnser.Action) [][]dnser.Action {
 	// Traverse the tree with DFS and for each node check
 	// if it needs to be upserted and the amount of predecessor
 	// nodes (increment count on each jump)
 	// then group by the amount of predecessor nodes
-
-	// The first list is delete actions,
-	// because they don't depend on anything else.
 	groups := make(map[int][]dnser.Action)
-	groups[0] = filterDeleteActions(actions)
+	upserted := make(map[config.Domain]int)
 
+	added := make(map[dnser.Action]bool)
 	addAction := func(action dnser.Action, bucket int) {
+		if added[action] {
+			return
+		}
+		added[action] = true
 		if groups[bucket] == nil {
 			groups[bucket] = make([]dnser.Action, 0)
 		}
 		groups[bucket] = append(groups[bucket], action)
+		upserted[action.Record.Name] = bucket
 	}
 	callback := func(domain config.Domain, dependentDomains int) bool {
-		dependencyAction := findDomainUpsertAction(domain, actions)
-		if dependencyAction == nil {
+		dependencyActions := findDomainUpsertActions(domain, actions)
+		if len(dependencyActions) == 0 {
 			return false
 		}
-		addAction(*dependencyAction, dependentDomains)
+		for _, action := range dependencyActions {
+			addAction(action, dependentDomains)
+		}
 		return true
 	}
 
@@ -85,20 +321,81 @@ func (m Massager) splitDependentActions(actions []dnser.Action) [][]dnser.Action
 		result[i] = group
 	}
 
-	return result
+	deletes := make([]dnser.Action, 0)
+	for _, action := range filterDeleteActions(actions) {
+		if i, ok := upserted[action.Record.Name]; ok {
+			result[i] = append(result[i], action)
+			continue
+		}
+		deletes = append(deletes, action)
+	}
+
+	return append(result, stageDeleteActions(deletes)...)
 }
 
-func findDomainUpsertAction(domain config.Domain, actions []dnser.Action) *dnser.Action {
-	for _, a := range actions {
-		if a.Record.Name == domain {
-			if a.Type == dnser.Upsert {
-				return &a
+// stageDeleteActions splits the deletes into stages, leaves first:
+// a record is deleted in a later stage than the records that alias it.
+func stageDeleteActions(deletes []dnser.Action) [][]dnser.Action {
+	aliasedBy := make(map[config.Domain][]int)
+	for i, action := range deletes {
+		if action.Record.Alias {
+			aliasedBy[action.Record.Target] = append(aliasedBy[action.Record.Target], i)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(deletes))
+	stages := make([]int, len(deletes))
+	var stage func(i int) int
+	stage = func(i int) int {
+		switch state[i] {
+		case visiting:
+			// the records alias each other, any order fails equally
+			return 0
+		case visited:
+			return stages[i]
+		}
+		state[i] = visiting
+		for _, child := range aliasedBy[deletes[i].Record.Name] {
+			if s := stage(child) + 1; s > stages[i] {
+				stages[i] = s
 			}
+		}
+		state[i] = visited
+		return stages[i]
+	}
 
-			return nil
+	result := make([][]dnser.Action, 0)
+	for i, action := range deletes {
+		s := stage(i)
+		for len(result) <= s {
+			result = append(result, make([]dnser.Action, 0))
 		}
+		result[s] = append(result[s], action)
 	}
-	return nil
+
+	// records in a loop skip stages
+	stagesWithActions := make([][]dnser.Action, 0, len(result))
+	for _, actions := range result {
+		if len(actions) > 0 {
+			stagesWithActions = append(stagesWithActions, actions)
+		}
+	}
+	return stagesWithActions
+}
+
+func findDomainUpsertActions(domain config.Domain, actions []dnser.Action) []dnser.Action {
+	result := make([]dnser.Action, 0)
+	for _, a := range actions {
+		if a.Record.Name == domain && a.Type == dnser.Upsert {
+			result = append(result, a)
+		}
+	}
+	return result
 }
 
 func filterDeleteActions(actions []dnser.Action) []dnser.Action {
@@ -145,23 +442,65 @@ func recordsToActions(records []dnser.DNSRecord, actionType dnser.ActionType) []
 	return result
 }
 
-func findPresentARecord(ip config.IP, domain config.Domain, records []dnser.DNSRecord) *dnser.DNSRecord {
-	shouldRecord := dnser.DNSRecord{
-		Alias:  false,
-		Name:   domain,
-		Target: config.Domain(ip),
+// rootRecord returns the record of the item's domain:
+// an A record to the IP, or an alias to the AWS resource.
+func rootRecord(cfg config.Item) dnser.DNSRecord {
+	if cfg.Alias == nil {
+		return dnser.DNSRecord{
+			Alias:   false,
+			Type:    dnser.RecordTypeOfIP(string(cfg.IP)),
+			Name:    cfg.Domain,
+			Target:  config.Domain(cfg.IP),
+			TTL:     cfg.TTL,
+			Routing: cfg.Routing,
+		}
 	}
-	if record := findRecordByName(domain, records); record != nil && *record == shouldRecord {
-		return record
+	return dnser.DNSRecord{
+		Alias:              true,
+		Name:               cfg.Domain,
+		Target:             cfg.Alias.DNSName,
+		TargetZoneID:       cfg.Alias.HostedZoneID,
+		IgnoreTargetHealth: !cfg.Alias.EvaluateTargetHealth,
+		Routing:            cfg.Routing,
 	}
-	return nil
+}
+
+// groupItemsByDomain groups the items that share a domain,
+// e.g. the records of a weighted routing policy.
+// The order of the first occurrence of every domain is preserved.
+func groupItemsByDomain(items []config.Item) [][]config.Item {
+	index := make(map[config.Domain]int)
+	result := make([][]config.Item, 0, len(items))
+	for _, item := range items {
+		i, ok := index[item.Domain]
+		if !ok {
+			i = len(result)
+			index[item.Domain] = i
+			result = append(result, make([]config.Item, 0, 1))
+		}
+		result[i] = append(result[i], item)
+	}
+	return result
+}
+
+func uniqueRecords(records []dnser.DNSRecord) []dnser.DNSRecord {
+	seen := make(map[dnser.DNSRecord]bool, len(records))
+	result := make([]dnser.DNSRecord, 0, len(records))
+	for _, r := range records {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		result = append(result, r)
+	}
+	return result
 }
 
 func findPutActions(have, want []dnser.DNSRecord) []dnser.DNSRecord {
 	actions := make([]dnser.DNSRecord, 0)
 	for _, wantRecord := range want {
-		haveRecord := findRecordByName(wantRecord.Name, have)
-		if haveRecord == nil || !(*haveRecord == wantRecord) {
+		haveRecord := findRecordByKey(wantRecord.Key(), have)
+		if haveRecord == nil || !sameRecord(*haveRecord, wantRecord) {
 			actions = append(actions, wantRecord)
 		}
 	}
@@ -169,10 +508,19 @@ func findPutActions(have, want []dnser.DNSRecord) []dnser.DNSRecord {
 	return actions
 }
 
+// sameRecord returns whether the records are equal.
+// The TTLs are only compared if both records have one.
+func sameRecord(a, b dnser.DNSRecord) bool {
+	if a.TTL == 0 || b.TTL == 0 {
+		a.TTL, b.TTL = 0, 0
+	}
+	return a == b
+}
+
 func findDeleteActions(have, want []dnser.DNSRecord) []dnser.DNSRecord {
 	actions := make([]dnser.DNSRecord, 0)
 	for _, haveRecord := range have {
-		wantRecord := findRecordByName(haveRecord.Name, want)
+		wantRecord := findRecordByKey(haveRecord.Key(), want)
 		if wantRecord == nil {
 			actions = append(actions, haveRecord)
 		}
@@ -181,43 +529,142 @@ func findDeleteActions(have, want []dnser.DNSRecord) []dnser.DNSRecord {
 	return actions
 }
 
-func findRecordByName(name config.Domain, records []dnser.DNSRecord) *dnser.DNSRecord {
+func findRecordByKey(key dnser.RecordKey, records []dnser.DNSRecord) *dnser.DNSRecord {
 	for _, r := range records {
-		if r.Name == name {
+		if r.Key() == key {
 			return &r
 		}
 	}
 	return nil
 }
 
-func transformIntoTree(parent config.Domain, records []dnser.DNSRecord) []config.Node {
+func findRecordsByName(name config.Domain, records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0)
+	for _, r := range records {
+		if r.Name == name {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// loop is a current record that aliases one of its own ancestors.
+type loop struct {
+	record dnser.DNSRecord
+	// path leads from the root of the tree to the target of the record.
+	path []config.Domain
+}
+
+func (l loop) warning() Warning {
+	names := []string{string(l.record.Name)}
+	for i := len(l.path) - 1; i >= 0; i-- {
+		names = append(names, string(l.path[i]))
+		if l.path[i] == l.record.Name {
+			break
+		}
+	}
+	return Warning{
+		Message: "alias loop: " + strings.Join(names, " -> "),
+		Records: []dnser.DNSRecord{l.record},
+	}
+}
+
+// transformIntoTree builds the tree of the records that alias parent.
+// The records that would close a loop are left out of the tree and returned instead.
+func transformIntoTree(parent config.Domain, records []dnser.DNSRecord) ([]config.Node, []loop) {
+	loops := make([]loop, 0)
+	tree := buildTree([]config.Domain{parent}, records, &loops)
+
+	seen := make(map[dnser.DNSRecord]bool, len(loops))
+	unique := make([]loop, 0, len(loops))
+	for _, l := range loops {
+		if !seen[l.record] {
+			seen[l.record] = true
+			unique = append(unique, l)
+		}
+	}
+	return tree, unique
+}
+
+func buildTree(path []config.Domain, records []dnser.DNSRecord, loops *[]loop) []config.Node {
+	parent := path[len(path)-1]
 	children := make([]config.Node, 0)
 	for _, r := range records {
 		if r.Target != parent {
 			continue
 		}
+		if containsDomain(path, r.Name) {
+			*loops = append(*loops, loop{record: r, path: append([]config.Domain(nil), path...)})
+			continue
+		}
 		children = append(children, config.Node{
 			Value:    r.Name,
-			Children: transformIntoTree(r.Name, records),
+			Children: buildTree(append(path[:len(path):len(path)], r.Name), records, loops),
 		})
 	}
 
 	return children
 }
 
-func flattenTree(parent config.Domain, nodes []config.Node) []dnser.DNSRecord {
+func containsDomain(domains []config.Domain, domain config.Domain) bool {
+	for _, d := range domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// treeOptions are the settings of an item that apply to every node of its tree.
+type treeOptions struct {
+	ignoreTargetHealth bool
+	// maxChain is the longest alias chain from a node to the roots, -1 means no limit.
+	maxChain int
+	// roots are the records of the domain that a node copies instead of exceeding maxChain.
+	roots []dnser.DNSRecord
+}
+
+// flattenTree returns the records of the nodes below parent,
+// which is chain aliases away from the roots.
+func flattenTree(parent config.Domain, nodes []config.Node, opts treeOptions, chain int) []dnser.DNSRecord {
 	if len(nodes) == 0 {
 		return nil
 	}
 
 	records := make([]dnser.DNSRecord, 0, len(nodes))
 	for _, node := range nodes {
-		records = append(records, dnser.DNSRecord{
-			Alias:  true,
-			Name:   node.Value,
-			Target: parent,
-		})
-		records = append(records, flattenTree(node.Value, node.Children)...)
+		nodeChain := chain + 1
+		if opts.maxChain >= 0 && nodeChain > opts.maxChain {
+			for _, root := range opts.roots {
+				root.Name = node.Value
+				records = append(records, root)
+			}
+			nodeChain = 0
+		} else {
+			records = append(records, dnser.DNSRecord{
+				Alias:              true,
+				Name:               node.Value,
+				Target:             parent,
+				IgnoreTargetHealth: opts.ignoreTargetHealth,
+			})
+		}
+		records = append(records, flattenTree(node.Value, node.Children, opts, nodeChain)...)
 	}
 	return records
 }
+
+// flattenCurrentTree flattens a tree built by transformIntoTree
+// back into the records it was built from.
+func flattenCurrentTree(parent config.Domain, nodes []config.Node, records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0, len(nodes))
+	for _, node := range nodes {
+		for _, r := range records {
+			if r.Name == node.Value && r.Target == parent {
+				result = append(result, r)
+				break
+			}
+		}
+		result = append(result, flattenCurrentTree(node.Value, node.Children, records)...)
+	}
+	return result
+}
@@ -70,14 +70,6 @@ var actions1 = []dnser.Action{{
 }
 
 var groupedActions1 = [][]dnser.Action{{
-	{
-		Type: dnser.Delete,
-		Record: dnser.DNSRecord{
-			Alias:  true,
-			Name:   "bar.foo.example.org.",
-			Target: "foo.example.org.",
-		},
-	},
 	{
 		Type: dnser.Upsert,
 		Record: dnser.DNSRecord{
@@ -102,9 +94,157 @@ var groupedActions1 = [][]dnser.Action{{
 			Target: "example.org.",
 		},
 	},
+}, {
+	{
+		Type: dnser.Delete,
+		Record: dnser.DNSRecord{
+			Alias:  true,
+			Name:   "bar.foo.example.org.",
+			Target: "foo.example.org.",
+		},
+	},
 },
 }
 
+var config2 = []config.Item{{
+	Alias: &config.AliasTarget{
+		Type:         config.ELB,
+		DNSName:      "my-lb.eu-west-1.elb.amazonaws.com.",
+		Region:       "eu-west-1",
+		HostedZoneID: "Z32O12XQLNTSW2",
+	},
+	Domain: "app.example.org.",
+	Aliases: []config.Node{{
+		Value:    "www.example.org.",
+		Children: nil,
+	}},
+	IgnoreTargetHealth: true,
+}}
+var set2 = []dnser.DNSRecord{{
+	Alias:        true,
+	Name:         "app.example.org.",
+	Target:       "my-lb.eu-west-1.elb.amazonaws.com.",
+	TargetZoneID: "Z32O12XQLNTSW2",
+}, {
+	Alias:  true,
+	Name:   "www.example.org.",
+	Target: "app.example.org.",
+}}
+var groupedActions2 = [][]dnser.Action{{{
+	Type: dnser.Upsert,
+	Record: dnser.DNSRecord{
+		Alias:              true,
+		Name:               "app.example.org.",
+		Target:             "my-lb.eu-west-1.elb.amazonaws.com.",
+		TargetZoneID:       "Z32O12XQLNTSW2",
+		IgnoreTargetHealth: true,
+	},
+}}, {{
+	Type: dnser.Upsert,
+	Record: dnser.DNSRecord{
+		Alias:              true,
+		Name:               "www.example.org.",
+		Target:             "app.example.org.",
+		IgnoreTargetHealth: true,
+	},
+}}}
+
+var blue = config.Routing{Policy: config.WeightedRouting, SetIdentifier: "blue", Weight: 90}
+var green = config.Routing{Policy: config.WeightedRouting, SetIdentifier: "green", Weight: 10}
+var config3 = []config.Item{{
+	IP:      "10.0.0.1",
+	Domain:  "app.example.org.",
+	Routing: blue,
+	Aliases: []config.Node{{
+		Value:    "www.example.org.",
+		Children: nil,
+	}},
+}, {
+	IP:      "10.0.0.2",
+	Domain:  "app.example.org.",
+	Routing: green,
+}}
+var set3 = []dnser.DNSRecord{{
+	Name:    "app.example.org.",
+	Target:  "10.0.0.1",
+	Routing: config.Routing{Policy: config.WeightedRouting, SetIdentifier: "blue", Weight: 100},
+}, {
+	Name:    "app.example.org.",
+	Target:  "10.0.0.3",
+	Routing: config.Routing{Policy: config.WeightedRouting, SetIdentifier: "grey", Weight: 0},
+}, {
+	Alias:  true,
+	Name:   "www.example.org.",
+	Target: "app.example.org.",
+}}
+var groupedActions3 = [][]dnser.Action{{{
+	Type: dnser.Upsert,
+	Record: dnser.DNSRecord{
+		Name:    "app.example.org.",
+		Target:  "10.0.0.1",
+		Routing: blue,
+	},
+}, {
+	Type: dnser.Upsert,
+	Record: dnser.DNSRecord{
+		Name:    "app.example.org.",
+		Target:  "10.0.0.2",
+		Routing: green,
+	},
+}, {
+	Type:   dnser.Delete,
+	Record: set3[1],
+}}}
+
+var config4 = []config.Item{{
+	IP:     "127.0.0.1",
+	Domain: "example.org.",
+	Aliases: []config.Node{{
+		Value:    "bar.example.org.",
+		Children: nil,
+	}},
+}}
+var set4 = []dnser.DNSRecord{{
+	Name:   "example.org.",
+	Target: "127.0.0.1",
+}, {
+	Alias:  true,
+	Name:   "foo.example.org.",
+	Target: "example.org.",
+}, {
+	Alias:  true,
+	Name:   "bar.example.org.",
+	Target: "foo.example.org.",
+}, {
+	Alias:  true,
+	Name:   "baz.foo.example.org.",
+	Target: "foo.example.org.",
+}, {
+	Alias:  true,
+	Name:   "qux.baz.foo.example.org.",
+	Target: "baz.foo.example.org.",
+}}
+
+// bar is re-parented before foo is deleted,
+// and the removed subtree is deleted leaves first.
+var groupedActions4 = [][]dnser.Action{{{
+	Type: dnser.Upsert,
+	Record: dnser.DNSRecord{
+		Alias:  true,
+		Name:   "bar.example.org.",
+		Target: "example.org.",
+	},
+}}, {{
+	Type:   dnser.Delete,
+	Record: set4[4],
+}}, {{
+	Type:   dnser.Delete,
+	Record: set4[3],
+}}, {{
+	Type:   dnser.Delete,
+	Record: set4[1],
+}}}
+
 func TestMassager_CalculateNeededActions(t *testing.T) {
 	type fields struct {
 		Desired []config.Item
@@ -121,6 +261,27 @@ func TestMassager_CalculateNeededActions(t *testing.T) {
 			Current: set1,
 		},
 		want: groupedActions1,
+	}, {
+		name: "alias target and target health",
+		fields: fields{
+			Desired: config2,
+			Current: set2,
+		},
+		want: groupedActions2,
+	}, {
+		name: "weighted routing",
+		fields: fields{
+			Desired: config3,
+			Current: set3,
+		},
+		want: groupedActions3,
+	}, {
+		name: "re-parenting and removing a subtree",
+		fields: fields{
+			Desired: config4,
+			Current: set4,
+		},
+		want: groupedActions4,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -169,3 +330,454 @@ func TestMassager_SplitDependentActions(t *testing.T) {
 		})
 	}
 }
+
+var config5 = []config.Item{{
+	IP:     "127.0.0.1",
+	Domain: "example.org.",
+	Aliases: []config.Node{{
+		Value:    "foo.example.org.",
+		Children: nil,
+	}},
+}}
+
+// bar and baz alias each other, and baz aliases itself in another weighted record.
+var set5 = []dnser.DNSRecord{{
+	Name:   "example.org.",
+	Target: "127.0.0.1",
+}, {
+	Alias:  true,
+	Name:   "foo.example.org.",
+	Target: "example.org.",
+}, {
+	Alias:  true,
+	Name:   "bar.example.org.",
+	Target: "foo.example.org.",
+}, {
+	Alias:  true,
+	Name:   "baz.example.org.",
+	Target: "bar.example.org.",
+}, {
+	Alias:   true,
+	Name:    "bar.example.org.",
+	Target:  "baz.example.org.",
+	Routing: config.Routing{Policy: config.WeightedRouting, SetIdentifier: "loop"},
+}, {
+	Alias:   true,
+	Name:    "baz.example.org.",
+	Target:  "baz.example.org.",
+	Routing: config.Routing{Policy: config.WeightedRouting, SetIdentifier: "self"},
+}}
+
+var warnings5 = []Warning{{
+	Message: "alias loop: bar.example.org. -> baz.example.org. -> bar.example.org.",
+	Records: []dnser.DNSRecord{set5[4]},
+}, {
+	Message: "alias loop: baz.example.org. -> baz.example.org.",
+	Records: []dnser.DNSRecord{set5[5]},
+}}
+
+func TestMassager_Plan(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakCycles bool
+		want        Plan
+	}{{
+		name: "report loops",
+		want: Plan{
+			Actions: [][]dnser.Action{{
+				{Type: dnser.Delete, Record: set5[3]},
+			}, {
+				{Type: dnser.Delete, Record: set5[2]},
+			}},
+			Warnings: warnings5,
+		},
+	}, {
+		name:        "break loops",
+		breakCycles: true,
+		want: Plan{
+			Actions: [][]dnser.Action{{
+				{Type: dnser.Delete, Record: set5[4]},
+			}, {
+				{Type: dnser.Delete, Record: set5[5]},
+			}, {
+				{Type: dnser.Delete, Record: set5[3]},
+			}, {
+				{Type: dnser.Delete, Record: set5[2]},
+			}},
+			Warnings: warnings5,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Massager{
+				Desired:     config5,
+				Current:     set5,
+				BreakCycles: tt.breakCycles,
+			}
+			if got := m.Plan(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var config6 = []config.Item{{
+	IP:     "10.0.0.1",
+	Domain: "www.example.org.",
+	Aliases: []config.Node{{
+		Value:    "app.example.org.",
+		Children: nil,
+	}, {
+		// a CNAME is not allowed at the apex
+		Value:    "example.net.",
+		Children: nil,
+	}},
+}, {
+	Alias: &config.AliasTarget{
+		Type:         config.CloudFront,
+		DNSName:      "d111111abcdef8.cloudfront.net.",
+		HostedZoneID: "Z2FDTNDATAQYW2",
+	},
+	Domain: "cdn.example.org.",
+	Aliases: []config.Node{{
+		Value:    "example.com.",
+		Children: nil,
+	}},
+}}
+var set6 = []dnser.DNSRecord{{
+	Name:   "www.example.org.",
+	Target: "10.0.0.1",
+}, {
+	Alias:  true,
+	Name:   "app.example.org.",
+	Target: "www.example.org.",
+}}
+
+func TestMassager_PlanCNAMEs(t *testing.T) {
+	m := Massager{
+		Desired:  config6,
+		Current:  set6,
+		Strategy: dnser.CNAMERecords,
+	}
+	want := Plan{
+		Actions: [][]dnser.Action{{
+			{Type: dnser.Upsert, Record: dnser.NewCNAMERecord("app.example.org.", "www.example.org.")},
+			{Type: dnser.Upsert, Record: dnser.NewRecord("example.net.", "10.0.0.1")},
+			{Type: dnser.Upsert, Record: dnser.NewCNAMERecord("cdn.example.org.", "d111111abcdef8.cloudfront.net.")},
+			{Type: dnser.Delete, Record: set6[1]},
+		}},
+		Warnings: []Warning{{
+			Message: "example.com. can't be a CNAME and cdn.example.org. has no addresses to copy",
+			Records: []dnser.DNSRecord{dnser.NewAliasRecord("example.com.", "cdn.example.org.")},
+		}},
+	}
+	if got := m.Plan(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Plan() = %v, want %v", got, want)
+	}
+}
+
+var config7 = []config.Item{{
+	IP:       "10.0.0.1",
+	Domain:   "example.org.",
+	MaxDepth: 1,
+	Aliases: []config.Node{{
+		Value: "www.example.org.",
+		Children: []config.Node{{
+			Value: "api.example.org.",
+			Children: []config.Node{{
+				Value:    "v1.api.example.org.",
+				Children: nil,
+			}},
+		}},
+	}},
+}}
+var set7 = []dnser.DNSRecord{
+	dnser.NewRecord("example.org.", "10.0.0.1"),
+	dnser.NewAliasRecord("www.example.org.", "example.org."),
+	dnser.NewAliasRecord("api.example.org.", "www.example.org."),
+	// a copy of the root whose node was removed from the config
+	dnser.NewRecord("old.example.org.", "10.0.0.1"),
+	dnser.NewAliasRecord("x.old.example.org.", "old.example.org."),
+	// records with the same address that aren't nodes of the tree
+	dnser.NewRecord("handmade.example.org.", "10.0.0.1"),
+	dnser.NewRecord("another.org.", "10.0.0.1"),
+}
+
+func TestMassager_PlanMaxDepth(t *testing.T) {
+	previous := []config.Item{{
+		IP:       "10.0.0.1",
+		Domain:   "example.org.",
+		MaxDepth: 1,
+		Aliases: append(config7[0].Aliases[:1:1], config.Node{
+			Value:    "old.example.org.",
+			Children: []config.Node{{Value: "x.old.example.org."}},
+		}),
+	}}
+	upserts := [][]dnser.Action{
+		{{Type: dnser.Upsert, Record: dnser.NewRecord("api.example.org.", "10.0.0.1")}},
+		{{Type: dnser.Upsert, Record: dnser.NewAliasRecord("v1.api.example.org.", "api.example.org.")}},
+	}
+	tests := []struct {
+		name         string
+		previous     []config.Item
+		want         [][]dnser.Action
+		wantWarnings []Warning
+	}{{
+		// without the previous config old.example.org. can't be told from a record made by hand
+		name: "no previous config",
+		want: upserts,
+		wantWarnings: []Warning{{
+			Message: "records outside of the config have the addresses of example.org., " +
+				"set Previous to delete them if they are copies of removed nodes",
+			Records: []dnser.DNSRecord{set7[3], set7[5], set7[6]},
+		}},
+	}, {
+		name:     "removed node",
+		previous: previous,
+		want: append(upserts[:2:2],
+			[]dnser.Action{{Type: dnser.Delete, Record: set7[4]}},
+			[]dnser.Action{{Type: dnser.Delete, Record: set7[3]}},
+		),
+		wantWarnings: []Warning{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Massager{
+				Desired:  config7,
+				Current:  set7,
+				Previous: tt.previous,
+			}
+			got := m.Plan()
+			if !reflect.DeepEqual(got.Actions, tt.want) {
+				t.Errorf("Plan() actions = %v, want %v", got.Actions, tt.want)
+			}
+			if !reflect.DeepEqual(got.Warnings, tt.wantWarnings) {
+				t.Errorf("Plan() warnings = %v, want %v", got.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func txtRecord(name, text string, ttl int64) dnser.DNSRecord {
+	return dnser.DNSRecord{Type: dnser.TypeTXT, Name: config.Domain(name), Target: config.Domain(text), TTL: ttl}
+}
+
+var records8 = []config.Record{{
+	Name:   "example.org.",
+	Type:   config.TXT,
+	TTL:    3600,
+	Values: []string{"v=spf1 -all", "verification=abc"},
+}, {
+	Name:   "example.org.",
+	Type:   config.MX,
+	TTL:    300,
+	Values: []string{"10 mail.example.org."},
+}}
+var set8 = []dnser.DNSRecord{
+	dnser.NewRecord("example.org.", "127.0.0.1"),
+	txtRecord("example.org.", "verification=abc", 300),
+	txtRecord("example.org.", "v=spf1 -all", 300),
+	{Type: dnser.TypeMX, Name: "example.org.", Target: "10 mail.example.org.", TTL: 300},
+	// a TXT record of a zone with TXT records in the config, but of another name
+	txtRecord("old.example.org.", "stale", 300),
+	// records of zones and types that are not in the config
+	txtRecord("example.com.", "keep", 300),
+	{Type: dnser.TypeCAA, Name: "example.org.", Target: `0 issue "letsencrypt.org"`, TTL: 300},
+}
+
+func TestMassager_PlanRecords(t *testing.T) {
+	upserts := []dnser.Action{
+		{Type: dnser.Upsert, Record: txtRecord("example.org.", "v=spf1 -all", 3600)},
+		{Type: dnser.Upsert, Record: txtRecord("example.org.", "verification=abc", 3600)},
+	}
+	tests := []struct {
+		name  string
+		prune bool
+		want  [][]dnser.Action
+	}{{
+		// the record sets of other names, e.g. ACME challenges, are not dnser's
+		name: "names of the config",
+		want: [][]dnser.Action{upserts},
+	}, {
+		name:  "prune",
+		prune: true,
+		want:  [][]dnser.Action{append(upserts[:2:2], dnser.Action{Type: dnser.Delete, Record: set8[4]})},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Massager{
+				Desired:         []config.Item{{IP: "127.0.0.1", Domain: "example.org."}},
+				Current:         set8,
+				Records:         records8,
+				PruneRecordSets: tt.prune,
+			}
+			if got := m.CalculateNeededActions(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CalculateNeededActions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMassager_PlanCNAMEsSRVTarget(t *testing.T) {
+	m := Massager{
+		Desired: []config.Item{{
+			IP:      "10.0.0.1",
+			Domain:  "example.org.",
+			Aliases: []config.Node{{Value: "api.example.org."}, {Value: "www.example.org."}},
+		}},
+		Records: []config.Record{{
+			Name:   "_http._tcp.example.org.",
+			Type:   config.SRV,
+			TTL:    300,
+			Values: []string{"10 5 8080 api.example.org."},
+		}},
+		Strategy: dnser.CNAMERecords,
+	}
+	srv := dnser.DNSRecord{Type: dnser.TypeSRV, Name: "_http._tcp.example.org.", Target: "10 5 8080 api.example.org.", TTL: 300}
+	want := [][]dnser.Action{{
+		{Type: dnser.Upsert, Record: dnser.NewRecord("example.org.", "10.0.0.1")},
+		{Type: dnser.Upsert, Record: srv},
+	}, {
+		// the target of an SRV record must not be a CNAME
+		{Type: dnser.Upsert, Record: dnser.NewRecord("api.example.org.", "10.0.0.1")},
+		{Type: dnser.Upsert, Record: dnser.NewCNAMERecord("www.example.org.", "example.org.")},
+	}}
+	if got := m.CalculateNeededActions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CalculateNeededActions() = %v, want %v", got, want)
+	}
+}
+
+func TestMassager_PlanCNAMEsCurrentRecordSets(t *testing.T) {
+	// a TXT record that isn't in the config, e.g. left behind without PruneRecordSets
+	txt := dnser.DNSRecord{Type: dnser.TypeTXT, Name: "www.example.org.", Target: "verification", TTL: 300}
+	m := Massager{
+		Desired: []config.Item{{
+			IP:      "10.0.0.1",
+			Domain:  "example.org.",
+			Aliases: []config.Node{{Value: "www.example.org."}},
+		}},
+		Current:  []dnser.DNSRecord{txt},
+		Strategy: dnser.CNAMERecords,
+	}
+	want := [][]dnser.Action{{
+		{Type: dnser.Upsert, Record: dnser.NewRecord("example.org.", "10.0.0.1")},
+	}, {
+		// a CNAME can't be next to the TXT record
+		{Type: dnser.Upsert, Record: dnser.NewRecord("www.example.org.", "10.0.0.1")},
+	}}
+	if got := m.CalculateNeededActions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CalculateNeededActions() = %v, want %v", got, want)
+	}
+}
+
+func TestMassager_PlanWildcards(t *testing.T) {
+	m := Massager{
+		Desired: []config.Item{{
+			IP:     "10.0.0.1",
+			Domain: "preview.example.org.",
+			Aliases: []config.Node{
+				{Value: "*.preview.example.org."},
+				{Value: "pr-1.preview.example.org."},
+			},
+		}},
+		Records: []config.Record{{
+			Name:   "_acme-challenge.preview.example.org.",
+			Type:   config.TXT,
+			TTL:    300,
+			Values: []string{"token"},
+		}},
+	}
+	want := []Warning{{
+		Message: "wildcard *.preview.example.org. doesn't apply to the explicit names " +
+			"pr-1.preview.example.org., _acme-challenge.preview.example.org.",
+		Records: []dnser.DNSRecord{
+			dnser.NewAliasRecord("pr-1.preview.example.org.", "preview.example.org."),
+			{Type: dnser.TypeTXT, Name: "_acme-challenge.preview.example.org.", Target: "token", TTL: 300},
+		},
+	}}
+	if got := m.Plan().Warnings; !reflect.DeepEqual(got, want) {
+		t.Errorf("Plan().Warnings = %v, want %v", got, want)
+	}
+}
+
+func ptrRecord(name, target string) dnser.DNSRecord {
+	return dnser.DNSRecord{Type: dnser.TypePTR, Name: config.Domain(name), Target: config.Domain(target)}
+}
+
+var set9 = []dnser.DNSRecord{
+	dnser.NewRecord("example.org.", "10.0.0.1"),
+	dnser.NewRecord("app.example.org.", "10.0.0.2"),
+	dnser.NewRecord("lan.example.org.", "192.168.0.1"),
+	ptrRecord("1.0.0.10.in-addr.arpa.", "example.org."),
+	ptrRecord("2.0.0.10.in-addr.arpa.", "old.example.org."),
+	ptrRecord("9.0.0.10.in-addr.arpa.", "gone.example.org."),
+	// a reverse zone that is not in ReverseZones
+	ptrRecord("1.0.168.192.in-addr.arpa.", "router.lan."),
+}
+
+func TestMassager_PlanPTR(t *testing.T) {
+	m := Massager{
+		Desired: []config.Item{
+			{IP: "10.0.0.1", Domain: "example.org."},
+			{IP: "10.0.0.2", Domain: "app.example.org."},
+			{IP: "192.168.0.1", Domain: "lan.example.org."},
+		},
+		Current:      set9,
+		ReverseZones: []config.Domain{"0.0.10.in-addr.arpa."},
+	}
+	want := [][]dnser.Action{{
+		{Type: dnser.Upsert, Record: ptrRecord("2.0.0.10.in-addr.arpa.", "app.example.org.")},
+		{Type: dnser.Delete, Record: set9[5]},
+	}}
+	if got := m.CalculateNeededActions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CalculateNeededActions() = %v, want %v", got, want)
+	}
+}
+
+func TestMassager_PlanTTL(t *testing.T) {
+	current := []dnser.DNSRecord{
+		{Name: "example.org.", Target: "10.0.0.1", TTL: 300},
+		{Name: "app.example.org.", Target: "10.0.0.2", TTL: 300},
+	}
+	m := Massager{
+		Desired: []config.Item{
+			{IP: "10.0.0.1", Domain: "example.org.", TTL: 60},
+			// no TTL: the current one is kept
+			{IP: "10.0.0.2", Domain: "app.example.org."},
+		},
+		Current: current,
+	}
+	want := [][]dnser.Action{{
+		{Type: dnser.Upsert, Record: dnser.DNSRecord{Name: "example.org.", Target: "10.0.0.1", TTL: 60}},
+	}}
+	if got := m.CalculateNeededActions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CalculateNeededActions() = %v, want %v", got, want)
+	}
+}
+
+func TestMassager_PlanCNAMEsRemovedCopy(t *testing.T) {
+	item := config.Item{
+		IP:      "10.0.0.1",
+		Domain:  "example.org.",
+		Aliases: []config.Node{{Value: "www.example.org."}},
+	}
+	previous := item
+	// a zone apex can't be a CNAME, so it got a copy of the address
+	previous.Aliases = append(item.Aliases[:1:1], config.Node{Value: "example.net."})
+	m := Massager{
+		Desired: []config.Item{item},
+		Current: []dnser.DNSRecord{
+			dnser.NewRecord("example.org.", "10.0.0.1"),
+			dnser.NewCNAMERecord("www.example.org.", "example.org."),
+			dnser.NewRecord("example.net.", "10.0.0.1"),
+		},
+		Previous: []config.Item{previous},
+		Strategy: dnser.CNAMERecords,
+	}
+	want := [][]dnser.Action{{
+		{Type: dnser.Delete, Record: dnser.NewRecord("example.net.", "10.0.0.1")},
+	}}
+	if got := m.CalculateNeededActions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CalculateNeededActions() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package massager
+
+import (
+	"strings"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+// ptrRecords returns the PTR records of the IPs of the items that are in one of the reverse zones.
+// An IP of several domains gets a PTR record per domain.
+func ptrRecords(items []config.Item, zones []config.Domain) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0)
+	for _, cfg := range items {
+		if cfg.Alias != nil {
+			continue
+		}
+		name, ok := cfg.IP.ReverseName()
+		if !ok || !inZones(name, zones) {
+			continue
+		}
+		result = append(result, dnser.DNSRecord{
+			Type:   dnser.TypePTR,
+			Name:   name,
+			Target: cfg.Domain,
+		})
+	}
+	return uniqueRecords(result)
+}
+
+// ptrActions returns the actions on the PTR records of the reverse zones.
+// All PTR records of the zones belong to dnser, other zones are left alone.
+func ptrActions(current, desired []dnser.DNSRecord, zones []config.Domain) []dnser.Action {
+	owned := make([]dnser.DNSRecord, 0)
+	for _, r := range current {
+		if r.Type == dnser.TypePTR && !r.Alias && inZones(r.Name, zones) {
+			owned = append(owned, r)
+		}
+	}
+	return diffRecordSets(owned, desired)
+}
+
+func inZones(name config.Domain, zones []config.Domain) bool {
+	for _, zone := range zones {
+		if name == zone || strings.HasSuffix(string(name), "."+string(zone)) {
+			return true
+		}
+	}
+	return false
+}
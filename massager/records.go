@@ -0,0 +1,159 @@
+package massager
+
+import (
+	"strings"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+// treeRecords returns the records that may be part of an alias tree:
+// aliases, addresses and CNAMEs.
+func treeRecords(records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0, len(records))
+	for _, r := range records {
+		if isTreeRecord(r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// nonTreeRecords returns the records that can't be part of an alias tree, e.g. TXT records.
+func nonTreeRecords(records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0)
+	for _, r := range records {
+		if !isTreeRecord(r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+func isTreeRecord(r dnser.DNSRecord) bool {
+	return r.Alias || r.Type == dnser.TypeA || r.Type == dnser.TypeAAAA || r.Type == dnser.TypeCNAME
+}
+
+// recordSetRecords returns a record per value of the record sets.
+func recordSetRecords(sets []config.Record) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, 0, len(sets))
+	for _, set := range sets {
+		for _, value := range set.Values {
+			result = append(result, dnser.DNSRecord{
+				Type:   dnser.RecordType(set.Type),
+				Name:   set.Name,
+				Target: config.Domain(value),
+				TTL:    set.TTL,
+			})
+		}
+	}
+	return result
+}
+
+// recordSetTarget returns the name an MX or SRV record points to.
+func recordSetTarget(r dnser.DNSRecord) (config.Domain, bool) {
+	if r.Type != dnser.TypeMX && r.Type != dnser.TypeSRV {
+		return "", false
+	}
+	fields := strings.Fields(string(r.Target))
+	if len(fields) == 0 {
+		return "", false
+	}
+	return config.Domain(fields[len(fields)-1]), true
+}
+
+// recordSetActions returns the actions that bring the current record sets to the desired ones.
+// Record sets are compared by name and type: a set whose values changed is upserted as a whole.
+// dnser owns the names and types of the desired sets. With prune it also owns the types
+// of the desired sets in their zones, and deletes the current sets of those that aren't desired.
+// The other current sets are left alone.
+func recordSetActions(current, desired []dnser.DNSRecord, prune bool) []dnser.Action {
+	type zoneType struct {
+		zone config.Domain
+		typ  dnser.RecordType
+	}
+	managedKeys := make(map[dnser.RecordKey]bool)
+	managedTypes := make(map[zoneType]bool)
+	for _, r := range desired {
+		managedKeys[r.Key()] = true
+		managedTypes[zoneType{zone: r.NameZone(), typ: r.Type}] = true
+	}
+	owned := make([]dnser.DNSRecord, 0)
+	for _, r := range current {
+		if r.Alias {
+			continue
+		}
+		if managedKeys[r.Key()] || (prune && managedTypes[zoneType{zone: r.NameZone(), typ: r.Type}]) {
+			owned = append(owned, r)
+		}
+	}
+
+	return diffRecordSets(owned, desired)
+}
+
+// diffRecordSets returns the actions that turn the current record sets into the desired ones:
+// a set whose values changed is upserted as a whole, a set missing in desired is deleted.
+func diffRecordSets(current, desired []dnser.DNSRecord) []dnser.Action {
+	desiredKeys, desiredSets := groupRecordsByKey(desired)
+	currentKeys, currentSets := groupRecordsByKey(current)
+
+	actions := make([]dnser.Action, 0)
+	for _, key := range desiredKeys {
+		currentSet, desiredSet := currentSets[key], desiredSets[key]
+		// the values of a set share a TTL
+		if len(currentSet) > 0 && (currentSet[0].TTL == 0 || desiredSet[0].TTL == 0) {
+			currentSet, desiredSet = withoutTTL(currentSet), withoutTTL(desiredSet)
+		}
+		if !sameRecords(currentSet, desiredSet) {
+			actions = append(actions, recordsToActions(desiredSets[key], dnser.Upsert)...)
+		}
+	}
+	for _, key := range currentKeys {
+		if _, ok := desiredSets[key]; !ok {
+			actions = append(actions, recordsToActions(currentSets[key], dnser.Delete)...)
+		}
+	}
+	return actions
+}
+
+// groupRecordsByKey groups the records by their key.
+// The keys are returned in the order of their first occurrence.
+func groupRecordsByKey(records []dnser.DNSRecord) ([]dnser.RecordKey, map[dnser.RecordKey][]dnser.DNSRecord) {
+	keys := make([]dnser.RecordKey, 0)
+	sets := make(map[dnser.RecordKey][]dnser.DNSRecord)
+	for _, r := range records {
+		key := r.Key()
+		if _, ok := sets[key]; !ok {
+			keys = append(keys, key)
+		}
+		sets[key] = append(sets[key], r)
+	}
+	return keys, sets
+}
+
+func withoutTTL(records []dnser.DNSRecord) []dnser.DNSRecord {
+	result := make([]dnser.DNSRecord, len(records))
+	for i, r := range records {
+		r.TTL = 0
+		result[i] = r
+	}
+	return result
+}
+
+// sameRecords returns whether a and b contain the same records in any order.
+func sameRecords(a, b []dnser.DNSRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[dnser.DNSRecord]int, len(a))
+	for _, r := range a {
+		counts[r]++
+	}
+	for _, r := range b {
+		if counts[r] == 0 {
+			return false
+		}
+		counts[r]--
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package massager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flood4life/dnser"
+	"github.com/flood4life/dnser/config"
+)
+
+// wildcardWarnings warns about the explicit names below a wildcard of the records.
+// A name that exists, even with records of another type, doesn't resolve through the wildcard,
+// and neither do the names below it.
+func wildcardWarnings(records []dnser.DNSRecord) []Warning {
+	names := recordNames(records)
+	warnings := make([]Warning, 0)
+	for _, wildcard := range names {
+		if !isWildcard(wildcard) {
+			continue
+		}
+		suffix := strings.TrimPrefix(string(wildcard), "*")
+
+		shadowed := make([]string, 0)
+		shadowedRecords := make([]dnser.DNSRecord, 0)
+		for _, name := range names {
+			if name != wildcard && strings.HasSuffix(string(name), suffix) {
+				shadowed = append(shadowed, string(name))
+				shadowedRecords = append(shadowedRecords, findRecordsByName(name, records)...)
+			}
+		}
+		if len(shadowed) == 0 {
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Message: fmt.Sprintf("wildcard %s doesn't apply to the explicit names %s", wildcard, strings.Join(shadowed, ", ")),
+			Records: shadowedRecords,
+		})
+	}
+	return warnings
+}
+
+// isWildcard returns whether the name is a wildcard name, e.g. *.preview.example.org.
+func isWildcard(name config.Domain) bool {
+	return strings.HasPrefix(string(name), "*.")
+}